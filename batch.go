@@ -0,0 +1,180 @@
+package telegram_notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// batchKey identifies the messages that can be combined into one.
+type batchKey struct {
+	title        string
+	parseMode    string
+	preformatted bool
+	silent       bool
+	threadId     int
+	chatIds      string
+	retry        RetryPolicy
+
+	// The overrides resolved with the config
+	disableWebPagePreview bool
+	protectContent        bool
+}
+
+func newBatchKey(msg TelegramMessage, cfg *validatedConfig) batchKey {
+	k := batchKey{
+		title:                 msg.Title,
+		parseMode:             msg.parseMode,
+		preformatted:          msg.preformatted,
+		silent:                msg.silent,
+		threadId:              msg.threadId,
+		disableWebPagePreview: cfg.DisableWebPagePreview,
+		protectContent:        cfg.ProtectContent,
+	}
+	if msg.disableWebPagePreview != nil {
+		k.disableWebPagePreview = *msg.disableWebPagePreview
+	}
+	if msg.protectContent != nil {
+		k.protectContent = *msg.protectContent
+	}
+	if msg.chatIds != nil {
+		k.chatIds = fmt.Sprint(msg.chatIds)
+	}
+	if msg.retry != nil {
+		k.retry = *msg.retry
+	}
+	return k
+}
+
+// batchMessages accumulates messages from tgMsgChan during the interval
+// and writes them combined into out until telegram service quit is requested.
+// When the unit is quitting, the messages are passed without delay.
+func (u *TelegramNotifier) batchMessages(out chan<- TelegramMessage, interval time.Duration) {
+	var pending []TelegramMessage
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	quitting := u.tgServiceQuitting
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timerC = nil, nil
+		}
+		for _, msg := range u.combineMessages(pending) {
+			out <- msg
+		}
+		pending = nil
+	}
+
+	for {
+		select {
+		case msg := <-u.tgMsgChan:
+			pending = append(pending, msg)
+			if quitting == nil {
+				flush()
+			} else if timer == nil {
+				timer = time.NewTimer(interval)
+				timerC = timer.C
+			}
+
+		case <-timerC:
+			timer, timerC = nil, nil
+			flush()
+
+		case <-quitting:
+			quitting = nil
+			// Take the messages enqueued before quitting into the batch
+			for taken := true; taken; {
+				select {
+				case msg := <-u.tgMsgChan:
+					pending = append(pending, msg)
+				default:
+					taken = false
+				}
+			}
+			flush()
+
+		case <-u.tgServiceQuitRequest:
+			// All messages are already sent
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// combineMessages groups the messages by title and delivery options
+// in the order of the first message of each group and joins the texts
+// of each group with line breaks. A group is combined into several messages
+// if the combined message would exceed MaxMessageLength.
+// Messages cancelled by the sender are skipped.
+func (u *TelegramNotifier) combineMessages(msgs []TelegramMessage) []TelegramMessage {
+	cfg := u.cfg()
+	maxLen := cfg.MaxMessageLength
+
+	var keys []batchKey
+	groups := make(map[batchKey][]TelegramMessage)
+	for _, msg := range msgs {
+		if msg.ctx.Err() != nil {
+			u.unpersistMessage(msg)
+			u.doneMessage(msg)
+			continue
+		}
+		k := newBatchKey(msg, cfg)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], msg)
+	}
+
+	var r []TelegramMessage
+	for _, k := range keys {
+		group := groups[k]
+		if len(group) == 1 {
+			r = append(r, group[0])
+			continue
+		}
+
+		var combined *TelegramMessage
+		var text strings.Builder
+		length := 0
+		add := func() {
+			combined.Text = text.String()
+			r = append(r, *combined)
+		}
+		for _, msg := range group {
+			msgLen := utf8.RuneCountInString(msg.Text)
+			if combined != nil && length+1+msgLen > maxLen {
+				add()
+				combined = nil
+			}
+			if combined == nil {
+				c := msg
+				// The messages are combined with different contexts
+				c.ctx = context.Background()
+				c.persistIds = append([]uint64(nil), msg.persistIds...)
+				// The grouped messages may have different overrides
+				// resolving to the same values
+				disableWebPagePreview := k.disableWebPagePreview
+				c.disableWebPagePreview = &disableWebPagePreview
+				protectContent := k.protectContent
+				c.protectContent = &protectContent
+				combined = &c
+				text.Reset()
+				text.WriteString(msg.Text)
+				length = composedTitleLen(msg.Title, cfg.TitleMode) + msgLen
+				continue
+			}
+			combined.batched++
+			combined.persistIds = append(combined.persistIds, msg.persistIds...)
+			text.WriteByte('\n')
+			text.WriteString(msg.Text)
+			length += 1 + msgLen
+		}
+		add()
+	}
+	return r
+}
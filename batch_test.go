@@ -0,0 +1,170 @@
+package telegram_notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchMessages(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.BatchIntervalMs = 500
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	var errorTexts []string
+	for i := 0; i < 10; i++ {
+		text := fmt.Sprintf("error %d", i)
+		errorTexts = append(errorTexts, text)
+		require.Equal(t, nil, tn.SendAsync("ERROR", text))
+		if i%4 == 0 {
+			require.Equal(t, nil, tn.SendAsync("INFO", fmt.Sprintf("info %d", i)))
+		}
+	}
+	require.Equal(t, nil, tn.SendSilent("ERROR", "silent"))
+
+	// Cancelled messages are skipped
+	ctx, cancel := context.WithCancel(context.Background())
+	require.Equal(t, nil, tn.SendAsyncCtx(ctx, "ERROR", "cancelled"))
+	cancel()
+
+	require.Equal(t, nil, tn.Flush(context.Background()))
+
+	sent := n.Sent()
+	require.Equal(t, 3, len(sent))
+	require.Equal(t, "ERROR", sent[0].Title)
+	require.Equal(t, strings.Join(errorTexts, "\n"), sent[0].Text)
+	require.Equal(t, "INFO", sent[1].Title)
+	require.Equal(t, "info 0\ninfo 4\ninfo 8", sent[1].Text)
+	require.Equal(t, "silent", sent[2].Text)
+	require.Equal(t, true, sent[2].silent)
+
+	s := tn.Stats()
+	require.Equal(t, uint64(14), s.Sent)
+	require.Equal(t, 0, s.Queued)
+
+	tn.UnitQuit()
+}
+
+func TestBatchMessagesMaxLength(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.BatchIntervalMs = 500
+	c.MaxMessageLength = 40
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, nil, tn.SendAsync("T", fmt.Sprintf("message %d", i)))
+	}
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	var texts []string
+	for _, m := range n.Sent() {
+		require.Equal(t, "T", m.Title)
+		texts = append(texts, m.Text)
+	}
+	require.Equal(t, []string{
+		"message 0\nmessage 1\nmessage 2",
+		"message 3\nmessage 4\nmessage 5",
+		"message 6\nmessage 7\nmessage 8",
+		"message 9",
+	}, texts)
+	require.Equal(t, uint64(10), tn.Stats().Sent)
+}
+
+func TestBatchMessagesWebPagePreview(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.BatchIntervalMs = 500
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// Only the messages with the same resolved value are combined
+	disabled, enabled := true, false
+	require.Equal(t, nil, tn.SendMessage(MessageOptions{Title: "T", Text: "default"}))
+	require.Equal(t, nil, tn.SendMessage(MessageOptions{Title: "T", Text: "disabled", DisableWebPagePreview: &disabled}))
+	require.Equal(t, nil, tn.SendMessage(MessageOptions{Title: "T", Text: "enabled", DisableWebPagePreview: &enabled}))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 2, len(sent))
+	require.Equal(t, "default\nenabled", sent[0].Text)
+	require.Equal(t, false, *sent[0].disableWebPagePreview)
+	require.Equal(t, "disabled", sent[1].Text)
+	require.Equal(t, true, *sent[1].disableWebPagePreview)
+}
+
+func TestBatchMessagesProtectContent(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.BatchIntervalMs = 500
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// A protected message is never combined with unprotected ones
+	protected, unprotected := true, false
+	require.Equal(t, nil, tn.SendAsync("ALERT", "public 1"))
+	require.Equal(t, nil, tn.SendMessage(MessageOptions{Title: "ALERT", Text: "secret", ProtectContent: &protected}))
+	require.Equal(t, nil, tn.SendMessage(MessageOptions{Title: "ALERT", Text: "public 2", ProtectContent: &unprotected}))
+	require.Equal(t, nil, tn.SendMessage(MessageOptions{Title: "ALERT", Text: "secret 2", ProtectContent: &protected}))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 2, len(sent))
+	require.Equal(t, "public 1\npublic 2", sent[0].Text)
+	require.Equal(t, false, *sent[0].protectContent)
+	require.Equal(t, "secret\nsecret 2", sent[1].Text)
+	require.Equal(t, true, *sent[1].protectContent)
+}
+
+func TestBatchMessagesQuit(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.BatchIntervalMs = 60000
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("T", "1"))
+	require.Equal(t, nil, tn.SendAsync("T", "2"))
+
+	// Pending batch is sent without waiting for the interval
+	start := time.Now()
+	r = tn.UnitQuit()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, r.CollateralError)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	sent := n.Sent()
+	require.Equal(t, 1, len(sent))
+	require.Equal(t, "1\n2", sent[0].Text)
+}
+
+func TestBatchIntervalValidation(t *testing.T) {
+	c := newTestConfig()
+	c.BatchIntervalMs = -1
+	_, err := New(t.Name(), c)
+	require.ErrorIs(t, err, ErrBadBatchInterval)
+}
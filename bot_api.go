@@ -0,0 +1,493 @@
+package telegram_notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// telegramApiBaseURL is the base URL of the official Telegram Bot API server.
+const telegramApiBaseURL = "https://api.telegram.org"
+
+// Parse modes supported by Telegram.
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+)
+
+// apiError is returned when Telegram Bot API rejects the request.
+type apiError struct {
+	Code        int
+	Description string
+	RetryAfter  int
+}
+
+func (e *apiError) Error() string {
+	return e.Description
+}
+
+// SendError is returned when a message fails to be sent to a Telegram chat.
+// Use errors.As to extract it from the errors returned by Send
+// or passed to the OnSendError callback.
+type SendError struct {
+	// ChatId is the chat the message failed to be sent to.
+	ChatId int64
+
+	// Retryable reports whether retrying the send may succeed,
+	// e.g. it is false for a chat that doesn't exist.
+	Retryable bool
+
+	// Err is the underlying error.
+	Err error
+}
+
+func newSendError(chatId int64, err error) *SendError {
+	retryable := !isPermanentSendError(err)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Code >= 400 && apiErr.Code < 500 &&
+		apiErr.Code != http.StatusTooManyRequests {
+		retryable = false
+	}
+	return &SendError{
+		ChatId:    chatId,
+		Retryable: retryable,
+		Err:       err,
+	}
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("failed to send message to Telegram chat '%d': %v", e.ChatId, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// sendErrors returns the *SendError the error consists of,
+// nil if it has other errors, i.e. the failed chats are unknown.
+func sendErrors(err error) []*SendError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var r []*SendError
+		for _, e := range joined.Unwrap() {
+			sendErrs := sendErrors(e)
+			if sendErrs == nil {
+				return nil
+			}
+			r = append(r, sendErrs...)
+		}
+		return r
+	}
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return []*SendError{sendErr}
+	}
+	return nil
+}
+
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// botAPI is a minimal Telegram Bot API client.
+type botAPI struct {
+	token   string
+	baseURL string
+	client  *http.Client
+
+	// callTimeout limits each Bot API call if not zero, so that
+	// sending to several chats doesn't share a single deadline.
+	callTimeout time.Duration
+}
+
+func newBotAPI(token string) *botAPI {
+	return &botAPI{
+		token:   token,
+		baseURL: telegramApiBaseURL,
+		client:  http.DefaultClient,
+	}
+}
+
+// newConfiguredBotAPI creates the Bot API client
+// using the connection settings from the config.
+func newConfiguredBotAPI(c *validatedConfig) *botAPI {
+	b := newBotAPI(c.BotToken)
+	b.callTimeout = c.SendTimeout
+	if c.ApiBaseURL != "" {
+		b.baseURL = c.ApiBaseURL
+	}
+	if c.ProxyURL != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		// The credentials in the URL are used to authenticate to the proxy
+		transport.Proxy = http.ProxyURL(c.ProxyURL)
+		b.client = &http.Client{Transport: transport}
+	}
+	return b
+}
+
+// call invokes the Bot API method with JSON-encoded params
+// and decodes the result into result if it is not nil.
+func (b *botAPI) call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := b.withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		b.baseURL+"/bot"+b.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return b.do(req, method, result)
+}
+
+// callMultipart invokes the Bot API method uploading the file data
+// in the fileField as multipart/form-data along with the other params
+// and decodes the result into result if it is not nil.
+func (b *botAPI) callMultipart(ctx context.Context, method string, params map[string]string,
+	fileField, filename string, data []byte, result any) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range params {
+		if err := w.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	fw, err := w.CreateFormFile(fileField, filename)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	ctx, cancel := b.withCallTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		b.baseURL+"/bot"+b.token+"/"+method, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	return b.do(req, method, result)
+}
+
+// withCallTimeout applies the call timeout to ctx.
+func (b *botAPI) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.callTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, b.callTimeout)
+}
+
+// do sends the request and decodes the Bot API response.
+func (b *botAPI) do(req *http.Request, method string, result any) error {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// url.Error contains the request URL with the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	var r apiResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		// E.g. a proxy error page
+		return fmt.Errorf("%s: unexpected response: %s", method, resp.Status)
+	}
+
+	if !r.Ok {
+		e := &apiError{
+			Code:        r.ErrorCode,
+			Description: r.Description,
+		}
+		if r.Parameters != nil {
+			e.RetryAfter = r.Parameters.RetryAfter
+		}
+		return e
+	}
+
+	if result != nil {
+		return json.Unmarshal(r.Result, result)
+	}
+	return nil
+}
+
+// getMe verifies the bot token.
+func (b *botAPI) getMe(ctx context.Context) error {
+	return b.call(ctx, "getMe", struct{}{}, nil)
+}
+
+type sendMessageParams struct {
+	ChatId              int64                 `json:"chat_id"`
+	MessageThreadId     int                   `json:"message_thread_id,omitempty"`
+	Text                string                `json:"text"`
+	ParseMode           string                `json:"parse_mode,omitempty"`
+	DisableNotification bool                  `json:"disable_notification,omitempty"`
+	DisableWebPreview   bool                  `json:"disable_web_page_preview,omitempty"`
+	ProtectContent      bool                  `json:"protect_content,omitempty"`
+	ReplyMarkup         *inlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type inlineKeyboardMarkup struct {
+	InlineKeyboard [][]Button `json:"inline_keyboard"`
+}
+
+type getChatParams struct {
+	ChatId string `json:"chat_id"`
+}
+
+type apiChat struct {
+	Id int64 `json:"id"`
+}
+
+// getChat returns the ID of the chat with the specified @username.
+func (b *botAPI) getChat(ctx context.Context, username string) (int64, error) {
+	var c apiChat
+	if err := b.call(ctx, "getChat", &getChatParams{ChatId: username}, &c); err != nil {
+		return 0, err
+	}
+	return c.Id, nil
+}
+
+// sendMessage sends the message and returns its ID.
+func (b *botAPI) sendMessage(ctx context.Context, p *sendMessageParams) (int, error) {
+	var m apiMessage
+	if err := b.call(ctx, "sendMessage", p, &m); err != nil {
+		return 0, err
+	}
+	return m.MessageId, nil
+}
+
+type sendLocationParams struct {
+	ChatId           int64   `json:"chat_id"`
+	MessageThreadId  int     `json:"message_thread_id,omitempty"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	ReplyToMessageId int     `json:"reply_to_message_id,omitempty"`
+	ProtectContent   bool    `json:"protect_content,omitempty"`
+}
+
+// sendLocation sends the point on the map.
+func (b *botAPI) sendLocation(ctx context.Context, p *sendLocationParams) error {
+	return b.call(ctx, "sendLocation", p, nil)
+}
+
+type editMessageTextParams struct {
+	ChatId    int64  `json:"chat_id"`
+	MessageId int    `json:"message_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// editMessageText replaces the text of the message.
+func (b *botAPI) editMessageText(ctx context.Context, p *editMessageTextParams) error {
+	return b.call(ctx, "editMessageText", p, nil)
+}
+
+type deleteMessageParams struct {
+	ChatId    int64 `json:"chat_id"`
+	MessageId int   `json:"message_id"`
+}
+
+// deleteMessage deletes the message.
+func (b *botAPI) deleteMessage(ctx context.Context, p *deleteMessageParams) error {
+	return b.call(ctx, "deleteMessage", p, nil)
+}
+
+// apiFile is a file sent to Telegram.
+type apiFile struct {
+	FileId string `json:"file_id"`
+}
+
+// apiMessage is a message returned by the Bot API.
+type apiMessage struct {
+	MessageId int       `json:"message_id"`
+	Document  *apiFile  `json:"document"`
+	Photo     []apiFile `json:"photo"`
+}
+
+// fileId returns the ID of the file sent in the message.
+func (m *apiMessage) fileId() string {
+	if m.Document != nil {
+		return m.Document.FileId
+	}
+	// Photo sizes are sent in ascending order
+	if len(m.Photo) > 0 {
+		return m.Photo[len(m.Photo)-1].FileId
+	}
+	return ""
+}
+
+// mediaParams are the common params of the methods sending files.
+type mediaParams struct {
+	ChatId          int64  `json:"chat_id"`
+	MessageThreadId int    `json:"message_thread_id,omitempty"`
+	Caption         string `json:"caption,omitempty"`
+	ParseMode       string `json:"parse_mode,omitempty"`
+	ProtectContent  bool   `json:"protect_content,omitempty"`
+}
+
+// fields returns the params as multipart/form-data fields.
+func (p *mediaParams) fields() map[string]string {
+	f := map[string]string{
+		"chat_id": strconv.FormatInt(p.ChatId, 10),
+	}
+	if p.MessageThreadId != 0 {
+		f["message_thread_id"] = strconv.Itoa(p.MessageThreadId)
+	}
+	if p.Caption != "" {
+		f["caption"] = p.Caption
+	}
+	if p.ParseMode != "" {
+		f["parse_mode"] = p.ParseMode
+	}
+	if p.ProtectContent {
+		f["protect_content"] = "true"
+	}
+	return f
+}
+
+// mediaMethod describes a Bot API method sending a file.
+type mediaMethod struct {
+	name      string
+	fileField string
+}
+
+var (
+	sendDocumentMethod = mediaMethod{name: "sendDocument", fileField: "document"}
+	sendPhotoMethod    = mediaMethod{name: "sendPhoto", fileField: "photo"}
+)
+
+// uploadFile uploads the file and returns its file ID
+// to send it to other chats without uploading it again.
+func (b *botAPI) uploadFile(ctx context.Context, m mediaMethod, p *mediaParams, filename string, data []byte) (string, error) {
+	var msg apiMessage
+	if err := b.callMultipart(ctx, m.name, p.fields(), m.fileField, filename, data, &msg); err != nil {
+		return "", err
+	}
+	return msg.fileId(), nil
+}
+
+type sendFileParams struct {
+	*mediaParams
+	Document string `json:"document,omitempty"`
+	Photo    string `json:"photo,omitempty"`
+}
+
+// sendFileByRef sends the file already uploaded to Telegram by its file ID
+// or the file available by HTTP URL.
+func (b *botAPI) sendFileByRef(ctx context.Context, m mediaMethod, p *mediaParams, ref string) error {
+	params := &sendFileParams{mediaParams: p}
+	switch m.fileField {
+	case sendDocumentMethod.fileField:
+		params.Document = ref
+	case sendPhotoMethod.fileField:
+		params.Photo = ref
+	}
+	return b.call(ctx, m.name, params, nil)
+}
+
+// botService sends messages to the configured chats via Telegram Bot API.
+// It implements notify.Notifier.
+type botService struct {
+	api         *botAPI
+	chatIds     []int64
+	chatThreads map[int64]int
+	parseMode   string
+	titleMode   string
+}
+
+// apiClient implements botAPIProvider.
+func (s *botService) apiClient() *botAPI {
+	return s.api
+}
+
+// threadId returns the message thread ID the messages
+// are sent to in the specified chat.
+func (s *botService) threadId(chatId int64) int {
+	return s.chatThreads[chatId]
+}
+
+// composeText combines the title and text of the message
+// according to the title mode, see Config.TitleMode.
+func composeText(title, text, titleMode string) string {
+	switch titleMode {
+	case TitleModeInline:
+		if title == "" {
+			return text
+		}
+		return title + ": " + text
+	case TitleModeNone:
+		return text
+	}
+	return title + "\n" + text
+}
+
+// Send sends the message to all configured chats.
+// Subject and message are combined according to the title mode.
+func (s *botService) Send(ctx context.Context, subject, message string) error {
+	return s.sendMessage(ctx, TelegramMessage{Title: subject, Text: message})
+}
+
+// sendMessage implements messageOptionsSender.
+func (s *botService) sendMessage(ctx context.Context, msg TelegramMessage) error {
+	p := &sendMessageParams{
+		Text:                composeText(msg.Title, msg.Text, s.titleMode),
+		ParseMode:           s.parseMode,
+		DisableNotification: msg.silent,
+		DisableWebPreview:   msg.disableWebPagePreview != nil && *msg.disableWebPagePreview,
+		ProtectContent:      msg.protectContent != nil && *msg.protectContent,
+	}
+	if msg.parseMode != "" {
+		p.ParseMode = msg.parseMode
+	}
+	chatIds := msg.chatIds
+	if chatIds == nil {
+		chatIds = s.chatIds
+	}
+	// A failure to deliver to one chat doesn't prevent delivery to the others
+	var errs []error
+	for _, chatId := range chatIds {
+		p.ChatId = chatId
+		// Chats without configured thread receive messages in the general topic
+		p.MessageThreadId = msg.threadId
+		if p.MessageThreadId == 0 {
+			p.MessageThreadId = s.chatThreads[chatId]
+		}
+		if _, err := s.api.sendMessage(ctx, p); err != nil {
+			errs = append(errs, newSendError(chatId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package telegram_notifier
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/nikoksr/notify"
+)
+
+// multiTokenNotifier sends messages via several bots, see Config.BotTokens.
+// The messages are distributed round-robin among the healthy bots,
+// i.e. the ones whose last send succeeded. If a send fails,
+// the message is sent via the next bot to the chats it failed to reach.
+type multiTokenNotifier struct {
+	notifiers []notify.Notifier
+	failed    []atomic.Bool
+	next      atomic.Uint64
+}
+
+// multiTokenBotService is the multiTokenNotifier whose bots all provide
+// the Bot API client, it implements botAPIProvider.
+type multiTokenBotService struct {
+	*multiTokenNotifier
+}
+
+// newMultiTokenNotifier creates a notifier for each of the bot tokens.
+// The bots that fail to initialize are skipped,
+// the error is returned only if all of them fail.
+func (u *TelegramNotifier) newMultiTokenNotifier(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+	m := &multiTokenNotifier{}
+	var errs []error
+	for _, token := range c.BotTokens {
+		tc := *c
+		tc.BotToken = token
+		n, err := u.newNotifier(ctx, &tc)
+		if err != nil {
+			errs = append(errs, err)
+			u.internalLog().Warn().Err(err).Str("bot_token", redactBotToken(token)).
+				Msg("failed to initialize Telegram bot, bot skipped")
+			continue
+		}
+		m.notifiers = append(m.notifiers, n)
+	}
+	if len(m.notifiers) == 0 {
+		// All errors are of the same kind if e.g. Telegram is unreachable
+		return nil, errs[len(errs)-1]
+	}
+	m.failed = make([]atomic.Bool, len(m.notifiers))
+
+	for _, n := range m.notifiers {
+		if _, ok := n.(botAPIProvider); !ok {
+			return m, nil
+		}
+	}
+	return multiTokenBotService{m}, nil
+}
+
+// order returns the indices of the bots in the order they are tried:
+// the healthy bots starting from the next one in turn, then the failed ones.
+func (m *multiTokenNotifier) order() []int {
+	start := int((m.next.Add(1) - 1) % uint64(len(m.notifiers)))
+	r := make([]int, 0, len(m.notifiers))
+	var failed []int
+	for k := range m.notifiers {
+		i := (start + k) % len(m.notifiers)
+		if m.failed[i].Load() {
+			failed = append(failed, i)
+			continue
+		}
+		r = append(r, i)
+	}
+	return append(r, failed...)
+}
+
+// Send implements notify.Notifier.
+func (m *multiTokenNotifier) Send(ctx context.Context, subject, message string) error {
+	return m.sendMessage(ctx, TelegramMessage{Title: subject, Text: message})
+}
+
+// sendMessage implements messageOptionsSender.
+// The error of the last bot tried is returned if all bots fail.
+func (m *multiTokenNotifier) sendMessage(ctx context.Context, msg TelegramMessage) error {
+	var err error
+	for _, i := range m.order() {
+		n := m.notifiers[i]
+		if ms, ok := n.(messageOptionsSender); ok {
+			err = ms.sendMessage(ctx, msg)
+		} else {
+			err = n.Send(ctx, msg.Title, msg.Text)
+		}
+		m.failed[i].Store(err != nil)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		// The chats that received the message must not receive it again
+		if chatIds := failedChatIds(err); chatIds != nil {
+			msg.chatIds = chatIds
+		}
+	}
+	return err
+}
+
+// apiClient implements botAPIProvider, the client of the first
+// healthy bot is returned.
+func (m multiTokenBotService) apiClient() *botAPI {
+	return m.provider().apiClient()
+}
+
+// threadId implements botAPIProvider.
+func (m multiTokenBotService) threadId(chatId int64) int {
+	return m.provider().threadId(chatId)
+}
+
+func (m multiTokenBotService) provider() botAPIProvider {
+	for i, n := range m.notifiers {
+		if !m.failed[i].Load() {
+			return n.(botAPIProvider)
+		}
+	}
+	return m.notifiers[0].(botAPIProvider)
+}
+
+// failedChatIds returns the chats of the *SendError the error consists of,
+// nil if it has other errors, i.e. the failed chats are unknown.
+func failedChatIds(err error) []int64 {
+	var r []int64
+	for _, e := range sendErrors(err) {
+		r = append(r, e.ChatId)
+	}
+	return r
+}
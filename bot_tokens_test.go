@@ -0,0 +1,121 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nikoksr/notify"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBotToken1 = "111111:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789"
+	testBotToken2 = "222222:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789"
+)
+
+// newMultiTokenTestNotifier creates a TelegramNotifier with two bot tokens
+// that uses the specified fake senders for the tokens.
+func newMultiTokenTestNotifier(t *testing.T, n1, n2 MessageSender) *TelegramNotifier {
+	c := newTestConfig()
+	c.BotToken = ""
+	c.BotTokens = []string{testBotToken1, testBotToken2}
+	c.ChatIds = []int64{1, 2}
+	c.SendConcurrency = 1
+	tn, err := New(t.Name(), c)
+	require.Equal(t, nil, err)
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		switch c.BotToken {
+		case testBotToken1:
+			if n1 == nil {
+				return nil, errors.New("Unauthorized")
+			}
+			return n1, nil
+		case testBotToken2:
+			return n2, nil
+		}
+		return nil, errors.New("unexpected bot token")
+	}
+	return tn
+}
+
+func TestBotTokensFailover(t *testing.T) {
+	n1 := &fakeNotifier{err: errors.New("Too Many Requests")}
+	n2 := &fakeNotifier{}
+	tn := newMultiTokenTestNotifier(t, n1, n2)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "1", "text"))
+	// The failed bot is tried after the healthy ones
+	require.Equal(t, nil, tn.Send(context.Background(), "2", "text"))
+	require.Equal(t, nil, tn.Send(context.Background(), "3", "text"))
+	tn.UnitQuit()
+
+	require.Equal(t, 1, n1.Calls())
+	require.Equal(t, []string{"1", "2", "3"}, sentTitles(n2))
+	require.Equal(t, uint64(0), tn.Stats().Retried)
+}
+
+func TestBotTokensRoundRobin(t *testing.T) {
+	n1 := &fakeNotifier{}
+	n2 := &fakeNotifier{}
+	tn := newMultiTokenTestNotifier(t, n1, n2)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	for _, title := range []string{"1", "2", "3", "4"} {
+		require.Equal(t, nil, tn.SendAsync(title, "text"))
+	}
+	tn.UnitQuit()
+
+	require.Equal(t, []string{"1", "3"}, sentTitles(n1))
+	require.Equal(t, []string{"2", "4"}, sentTitles(n2))
+}
+
+func TestBotTokensFailedChats(t *testing.T) {
+	// The first bot reached chat 1, only chat 2 is left for the next bot
+	n1 := &fakeNotifier{failures: []error{errors.Join(newSendError(2, errors.New("timeout")))}}
+	n2 := &fakeNotifier{}
+	tn := newMultiTokenTestNotifier(t, n1, n2)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	tn.UnitQuit()
+
+	require.Equal(t, 1, len(n2.Sent()))
+	require.Equal(t, []int64{2}, n2.Sent()[0].chatIds)
+}
+
+func TestBotTokensInit(t *testing.T) {
+	// The bot that fails to initialize is skipped
+	n2 := &fakeNotifier{}
+	tn := newMultiTokenTestNotifier(t, nil, n2)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "1", "text"))
+	require.Equal(t, nil, tn.Send(context.Background(), "2", "text"))
+	tn.UnitQuit()
+	require.Equal(t, []string{"1", "2"}, sentTitles(n2))
+}
+
+func TestBotTokensValidation(t *testing.T) {
+	c := newTestConfig()
+	c.BotTokens = []string{testBotToken1, testBotToken2}
+	require.ErrorIs(t, c.Validate(), ErrBadTelegramBotToken, "bot_token and bot_tokens are mutually exclusive")
+
+	c.BotToken = ""
+	require.Equal(t, nil, c.Validate())
+
+	c.BotTokens = []string{testBotToken1, "not a token"}
+	err := c.Validate()
+	require.ErrorIs(t, err, ErrBadTelegramBotToken)
+	require.Contains(t, err.Error(), "token 1 of bot_tokens")
+
+	// The tokens are redacted
+	c.BotTokens = []string{testBotToken1, testBotToken2}
+	require.NotContains(t, c.String(), testBotToken1)
+	require.NotContains(t, c.String(), testBotToken2)
+}
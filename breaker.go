@@ -0,0 +1,133 @@
+package telegram_notifier
+
+import (
+	"sync"
+
+	"github.com/igulib/app"
+)
+
+// Circuit breaker states, see Config.FailureThreshold.
+const (
+	// BreakerStateClosed means the messages are sent normally.
+	BreakerStateClosed = "closed"
+
+	// BreakerStateOpen means the sends are paused after repeated failures,
+	// the unit is temporarily unavailable.
+	BreakerStateOpen = "open"
+
+	// BreakerStateHalfOpen means the cooldown is over and the result
+	// of the next send decides whether the breaker closes or opens again.
+	BreakerStateHalfOpen = "half_open"
+)
+
+// circuitBreaker pauses sends after repeated failures.
+// The zero value is a closed breaker.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	timer    clockTimer
+
+	// paused is true if the breaker made the unit temporarily unavailable.
+	paused bool
+}
+
+// breakerState returns the current circuit breaker state.
+func (u *TelegramNotifier) breakerState() string {
+	b := &u.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == "" {
+		return BreakerStateClosed
+	}
+	return b.state
+}
+
+// breakerAllows returns false if the sends are paused by the circuit breaker.
+func (u *TelegramNotifier) breakerAllows() bool {
+	return u.breakerState() != BreakerStateOpen
+}
+
+// recordSendResult updates the circuit breaker with the final result
+// of sending a message. Messages cancelled by the sender must not be recorded.
+func (u *TelegramNotifier) recordSendResult(err error) {
+	cfg := u.cfg()
+	if cfg.FailureThreshold == 0 {
+		return
+	}
+
+	b := &u.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		if b.state == BreakerStateHalfOpen {
+			b.state = BreakerStateClosed
+			u.internalLog().Info().Msg("circuit breaker closed, sends resumed")
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerStateOpen ||
+		(b.state != BreakerStateHalfOpen && b.failures < cfg.FailureThreshold) {
+		return
+	}
+
+	b.state = BreakerStateOpen
+	u.availabilityLock.Lock()
+	if u.availability == app.UAvailable {
+		u.availability = app.UTemporarilyUnavailable
+		u.availabilityReason = AvailabilityReasonCircuitOpen
+		b.paused = true
+	}
+	u.availabilityLock.Unlock()
+	u.internalLog().Warn().Int("failures", b.failures).Dur("cooldown", cfg.BreakerCooldown).
+		Msg("circuit breaker open, sends paused")
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = u.clock.AfterFunc(cfg.BreakerCooldown, u.halfOpenBreaker)
+}
+
+// halfOpenBreaker allows a probe send after the cooldown.
+// The unit is made available again if it was made unavailable by the breaker
+// and wasn't paused meanwhile.
+func (u *TelegramNotifier) halfOpenBreaker() {
+	b := &u.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerStateOpen {
+		return
+	}
+	b.state = BreakerStateHalfOpen
+	b.timer = nil
+	if b.paused {
+		b.paused = false
+		u.availabilityLock.Lock()
+		if u.availability == app.UTemporarilyUnavailable &&
+			u.availabilityReason == AvailabilityReasonCircuitOpen {
+			u.availability = app.UAvailable
+			u.availabilityReason = AvailabilityReasonAvailable
+		}
+		u.availabilityLock.Unlock()
+	}
+}
+
+// resetBreaker closes the circuit breaker, e.g. when the unit starts or quits.
+func (u *TelegramNotifier) resetBreaker() {
+	b := &u.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.state = BreakerStateClosed
+	b.failures = 0
+	b.paused = false
+}
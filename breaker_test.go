@@ -0,0 +1,128 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igulib/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	permanent := errors.New("Bad Request: chat not found")
+	n := &fakeNotifier{err: permanent, delay: 50 * time.Millisecond}
+	c := newTestConfig()
+	c.FailureThreshold = 2
+	c.BreakerCooldownMs = 200
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	var mu sync.Mutex
+	var callbackErrs []error
+	tn.SetOnSendError(func(msg TelegramMessage, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		callbackErrs = append(callbackErrs, err)
+	})
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The messages buffered after the breaker opens are not sent
+	for i := 0; i < 5; i++ {
+		require.Equal(t, nil, tn.SendAsync("title", "text"))
+	}
+	require.Eventually(t, func() bool {
+		return tn.Stats().Failed == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, n.Calls())
+	require.Equal(t, BreakerStateOpen, tn.Stats().BreakerState)
+	require.Equal(t, app.UTemporarilyUnavailable, tn.UnitAvailability())
+	require.Equal(t, AvailabilityReasonCircuitOpen, tn.AvailabilityReason())
+	require.ErrorIs(t, tn.SendAsync("title", "text"), ErrUnitNotAvailable)
+	mu.Lock()
+	require.Equal(t, []error{permanent, permanent, ErrCircuitOpen, ErrCircuitOpen, ErrCircuitOpen}, callbackErrs)
+	mu.Unlock()
+
+	// Failed probe opens the breaker again
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UAvailable
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, BreakerStateHalfOpen, tn.Stats().BreakerState)
+	require.ErrorIs(t, tn.Send(context.Background(), "title", "text"), permanent)
+	require.Equal(t, BreakerStateOpen, tn.Stats().BreakerState)
+	require.Equal(t, app.UTemporarilyUnavailable, tn.UnitAvailability())
+
+	// Successful probe closes the breaker
+	n.mu.Lock()
+	n.err = nil
+	n.mu.Unlock()
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UAvailable
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, nil, tn.SendAsync("title", "probe"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, BreakerStateClosed, tn.Stats().BreakerState)
+	require.Equal(t, 1, len(n.Sent()))
+
+	tn.UnitQuit()
+	require.Equal(t, app.UNotAvailable, tn.UnitAvailability())
+}
+
+func TestCircuitBreakerKeepsPause(t *testing.T) {
+	n := &fakeNotifier{err: errors.New("Bad Request: chat not found")}
+	c := newTestConfig()
+	c.FailureThreshold = 1
+	c.BreakerCooldownMs = 50
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.ErrorIs(t, tn.Send(context.Background(), "title", "text"), n.err)
+	require.Equal(t, AvailabilityReasonCircuitOpen, tn.AvailabilityReason())
+
+	// Paused while the breaker is open
+	r = tn.UnitPause()
+	require.Equal(t, true, r.OK)
+	require.Eventually(t, func() bool {
+		return tn.Stats().BreakerState == BreakerStateHalfOpen
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, app.UTemporarilyUnavailable, tn.UnitAvailability(), "the cooldown must not resume the paused unit")
+	require.Equal(t, AvailabilityReasonPaused, tn.AvailabilityReason())
+
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, app.UAvailable, tn.UnitAvailability())
+	tn.UnitQuit()
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	n := &fakeNotifier{err: errors.New("Bad Request: chat not found")}
+	c := newTestConfig()
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, nil, tn.SendAsync("title", "text"))
+	}
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, 10, n.Calls())
+	require.Equal(t, BreakerStateClosed, tn.Stats().BreakerState)
+	require.Equal(t, app.UAvailable, tn.UnitAvailability())
+
+	tn.UnitQuit()
+}
+
+func TestCircuitBreakerValidation(t *testing.T) {
+	c := newTestConfig()
+	c.FailureThreshold = -1
+	c.BreakerCooldownMs = -1
+	_, err := New(t.Name(), c)
+	require.ErrorIs(t, err, ErrBadFailureThreshold)
+	require.ErrorIs(t, err, ErrBadBreakerCooldown)
+}
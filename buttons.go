@@ -0,0 +1,78 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Button is an inline keyboard button attached to a message.
+// Exactly one of URL and CallbackData must be specified.
+type Button struct {
+	// Text is the label of the button.
+	Text string `json:"text"`
+
+	// URL is opened when the button is pressed.
+	URL string `json:"url,omitempty"`
+
+	// CallbackData (1-64 bytes) is sent to the bot when the button is pressed.
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// validate returns an error if the button is not valid.
+func (b *Button) validate() error {
+	if b.Text == "" {
+		return fmt.Errorf("%w: empty text", ErrBadButton)
+	}
+	if (b.URL == "") == (b.CallbackData == "") {
+		return fmt.Errorf("%w: %q must have either URL or callback data", ErrBadButton, b.Text)
+	}
+	if len(b.CallbackData) > 64 {
+		return fmt.Errorf("%w: %q callback data exceeds 64 bytes", ErrBadButton, b.Text)
+	}
+	return nil
+}
+
+// SendWithButtons synchronously sends the message with the inline keyboard
+// to the configured chats, it is thread-safe. Each element of buttons
+// is a row of the keyboard. All invalid buttons are reported as
+// ErrBadButton before sending. The message is not split,
+// so it must not exceed MaxMessageLength.
+// A failure to deliver to one chat doesn't prevent delivery to the others,
+// the errors are returned joined as *SendError.
+func (u *TelegramNotifier) SendWithButtons(title, text string, buttons [][]Button) error {
+	var errs []error
+	for _, row := range buttons {
+		for i := range row {
+			if err := row[i].validate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	cfg := u.cfg()
+	chatIds := u.mutedChats.unmuted(cfg.ChatIds)
+	msg := u.autoEscape(TelegramMessage{Title: title, Text: text, parseMode: cfg.ParseMode})
+	markup := &inlineKeyboardMarkup{InlineKeyboard: buttons}
+	return u.callAPI(context.Background(), chatIds, func(ctx context.Context, api botAPIProvider) error {
+		var errs []error
+		for _, chatId := range chatIds {
+			_, err := api.apiClient().sendMessage(ctx, &sendMessageParams{
+				ChatId:            chatId,
+				MessageThreadId:   api.threadId(chatId),
+				Text:              composeText(msg.Title, msg.Text, cfg.TitleMode),
+				ParseMode:         msg.parseMode,
+				DisableWebPreview: cfg.DisableWebPagePreview,
+				ProtectContent:    cfg.ProtectContent,
+				ReplyMarkup:       markup,
+			})
+			if err != nil {
+				errs = append(errs, newSendError(chatId, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
@@ -0,0 +1,56 @@
+package telegram_notifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendWithButtons(t *testing.T) {
+	s := newFakeBotAPIServer(t, nil)
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	err := tn.SendWithButtons("ALERT", "disk full", [][]Button{
+		{{Text: "Acknowledge", CallbackData: "ack:42"}, {Text: "Silence", CallbackData: "silence:42"}},
+		{{Text: "Dashboard", URL: "https://grafana.example.com/d/42"}},
+	})
+	require.Equal(t, nil, err)
+
+	requests := s.Requests()
+	require.Equal(t, 3, len(requests))
+	require.True(t, strings.HasSuffix(requests[1].Path, "/sendMessage"))
+	require.Equal(t, float64(1), requests[1].Params["chat_id"])
+	require.Equal(t, float64(2), requests[2].Params["chat_id"])
+	require.Equal(t, "ALERT\ndisk full", requests[1].Params["text"])
+	require.Equal(t, map[string]any{
+		"inline_keyboard": []any{
+			[]any{
+				map[string]any{"text": "Acknowledge", "callback_data": "ack:42"},
+				map[string]any{"text": "Silence", "callback_data": "silence:42"},
+			},
+			[]any{
+				map[string]any{"text": "Dashboard", "url": "https://grafana.example.com/d/42"},
+			},
+		},
+	}, requests[1].Params["reply_markup"])
+
+	// Invalid buttons are reported before sending
+	err = tn.SendWithButtons("ALERT", "disk full", [][]Button{
+		{{Text: "Both", URL: "https://example.com", CallbackData: "both"}},
+		{{Text: "None"}, {URL: "https://example.com"}},
+		{{Text: "Long", CallbackData: strings.Repeat("x", 65)}},
+	})
+	require.ErrorIs(t, err, ErrBadButton)
+	for _, text := range []string{`"Both"`, `"None"`, "empty text", `"Long"`} {
+		require.Contains(t, err.Error(), text)
+	}
+	require.Equal(t, 3, len(s.Requests()))
+
+	tn.UnitQuit()
+}
@@ -0,0 +1,50 @@
+package telegram_notifier
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// callerSkipPrefixes are the function name prefixes of the frames
+// between the log call site and the hook: the logging libraries
+// and this package.
+var callerSkipPrefixes = []string{
+	"github.com/rs/zerolog.",
+	"github.com/rs/zerolog/",
+	"log/slog.",
+	reflect.TypeOf(TelegramNotifier{}).PkgPath() + ".",
+}
+
+// logCaller returns the "file:line" of the log call site
+// or an empty string if it is not found.
+func logCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// isInternalFrame returns true if the frame belongs to a logging library
+// or to this package, except for its tests.
+func isInternalFrame(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	for _, p := range callerSkipPrefixes {
+		if strings.HasPrefix(frame.Function, p) {
+			return true
+		}
+	}
+	return false
+}
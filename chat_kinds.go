@@ -0,0 +1,60 @@
+package telegram_notifier
+
+import "fmt"
+
+// ChatKind is the kind of a Telegram chat told by its ID.
+type ChatKind string
+
+const (
+	// ChatKindUser is a private chat with a user, its ID is positive.
+	ChatKindUser ChatKind = "user"
+
+	// ChatKindGroup is a basic group, its ID is negative.
+	ChatKindGroup ChatKind = "group"
+
+	// ChatKindChannel is a channel or a supergroup,
+	// its ID is negative and starts with -100, e.g. -1001234567890.
+	ChatKindChannel ChatKind = "channel"
+
+	// ChatKindUnknown is returned for the zero ID.
+	ChatKindUnknown ChatKind = ""
+)
+
+// minGroupChatId is the lowest ID of a basic group,
+// lower IDs belong to channels and supergroups.
+const minGroupChatId = -999_999_999_999
+
+// ChatIdKind returns the kind of the chat with the specified ID.
+func ChatIdKind(chatId int64) ChatKind {
+	switch {
+	case chatId > 0:
+		return ChatKindUser
+	case chatId == 0:
+		return ChatKindUnknown
+	case chatId >= minGroupChatId:
+		return ChatKindGroup
+	default:
+		return ChatKindChannel
+	}
+}
+
+// checkChatIdKinds returns the errors for the chat IDs
+// of the kinds not allowed by the config.
+func checkChatIdKinds(c *Config, chatIds []int64) []error {
+	var errs []error
+	for _, id := range chatIds {
+		switch ChatIdKind(id) {
+		case ChatKindUnknown:
+			errs = append(errs, fmt.Errorf("%w: chat ID can't be zero", ErrBadTelegramChatId))
+		case ChatKindUser:
+			if c.AllowUserIds != nil && !*c.AllowUserIds {
+				errs = append(errs, fmt.Errorf("%w: %d is a user chat ID, but allow_user_ids is false; group and channel IDs are negative", ErrBadTelegramChatId, id))
+			}
+		case ChatKindChannel:
+			if c.AllowChannelIds != nil && !*c.AllowChannelIds {
+				errs = append(errs, fmt.Errorf("%w: %d is a channel or supergroup chat ID, but allow_channel_ids is false", ErrBadTelegramChatId, id))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,57 @@
+package telegram_notifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatIdKind(t *testing.T) {
+	require.Equal(t, ChatKindUser, ChatIdKind(123456789))
+	require.Equal(t, ChatKindGroup, ChatIdKind(-123456789))
+	require.Equal(t, ChatKindGroup, ChatIdKind(-999999999999))
+	require.Equal(t, ChatKindChannel, ChatIdKind(-1000000000000))
+	require.Equal(t, ChatKindChannel, ChatIdKind(-1001234567890))
+	require.Equal(t, ChatKindUnknown, ChatIdKind(0))
+}
+
+func TestChatIdKindValidation(t *testing.T) {
+	allow, deny := true, false
+	const user, group, channel = int64(123456789), int64(-123456789), int64(-1001234567890)
+
+	// All kinds are allowed by default
+	c := newTestConfig()
+	c.ChatIds = []int64{user, group, channel}
+	require.Equal(t, nil, c.Validate())
+
+	c.ChatIds = []int64{user, 0}
+	err := c.Validate()
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.Contains(t, err.Error(), "can't be zero")
+
+	c.ChatIds = []int64{user, group, channel}
+	c.AllowUserIds = &deny
+	err = c.Validate()
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.Contains(t, err.Error(), "123456789 is a user chat ID")
+	require.NotContains(t, err.Error(), "-1001234567890")
+
+	c.AllowUserIds = &allow
+	c.AllowChannelIds = &deny
+	err = c.Validate()
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.Contains(t, err.Error(), "-1001234567890 is a channel")
+	require.NotContains(t, err.Error(), "-123456789 ")
+
+	c.ChatIds = []int64{group}
+	c.AllowUserIds = &deny
+	require.Equal(t, nil, c.Validate())
+
+	// Chat IDs from the environment variable are checked too
+	t.Setenv("TEST_CHAT_IDS", "-1001234567890")
+	c = newTestConfig()
+	c.ChatIds = nil
+	c.ChatIdsEnvVar = "TEST_CHAT_IDS"
+	c.AllowChannelIds = &deny
+	require.ErrorIs(t, c.Validate(), ErrBadTelegramChatId)
+}
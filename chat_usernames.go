@@ -0,0 +1,71 @@
+package telegram_notifier
+
+import (
+	"context"
+
+	"github.com/nikoksr/notify"
+)
+
+// resolveChatUsernames resolves Config.ChatUsernames to chat IDs
+// and adds them to the configured chats. The usernames resolved before
+// are taken from the cache. Failures are logged and the chats are skipped.
+func (u *TelegramNotifier) resolveChatUsernames(notifier notify.Notifier) {
+	cfg := u.cfg()
+	if len(cfg.ChatUsernames) == 0 {
+		return
+	}
+	api, ok := notifier.(botAPIProvider)
+	if !ok {
+		u.internalLog().Warn().Strs("chat_usernames", cfg.ChatUsernames).
+			Msg("chat usernames can't be resolved without Telegram Bot API, skipped")
+		return
+	}
+
+	for _, username := range cfg.ChatUsernames {
+		if _, ok := u.cachedChatUsernameId(username); ok {
+			continue
+		}
+		select {
+		case <-u.tgServiceQuitting:
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.SendTimeout)
+		id, err := api.apiClient().getChat(ctx, username)
+		cancel()
+		if err != nil {
+			u.internalLog().Error().Err(err).Str("chat_username", username).
+				Msg("failed to resolve chat username, skipped")
+			continue
+		}
+		u.chatUsernameIdsLock.Lock()
+		if u.chatUsernameIds == nil {
+			u.chatUsernameIds = make(map[string]int64)
+		}
+		u.chatUsernameIds[username] = id
+		u.chatUsernameIdsLock.Unlock()
+	}
+
+	u.updateConfig(u.addCachedChatUsernameIds)
+}
+
+// addCachedChatUsernameIds adds the cached IDs of the config chat usernames
+// to its chats.
+func (u *TelegramNotifier) addCachedChatUsernameIds(c *validatedConfig) {
+	chatIds := append([]int64(nil), c.ChatIds...)
+	for _, username := range c.ChatUsernames {
+		id, ok := u.cachedChatUsernameId(username)
+		if ok && !containsChatId(chatIds, id) {
+			chatIds = append(chatIds, id)
+		}
+	}
+	c.ChatIds = chatIds
+}
+
+func (u *TelegramNotifier) cachedChatUsernameId(username string) (int64, bool) {
+	u.chatUsernameIdsLock.Lock()
+	defer u.chatUsernameIdsLock.Unlock()
+	id, ok := u.chatUsernameIds[username]
+	return id, ok
+}
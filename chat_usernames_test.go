@@ -0,0 +1,75 @@
+package telegram_notifier
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatUsernames(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		if !strings.HasSuffix(r.Path, "/getChat") {
+			return http.StatusOK, `{"ok":true,"result":{}}`
+		}
+		switch r.Params["chat_id"] {
+		case "@alerts_channel":
+			return http.StatusOK, `{"ok":true,"result":{"id":-1001234567890}}`
+		case "@ops_group":
+			return http.StatusOK, `{"ok":true,"result":{"id":1}}`
+		}
+		return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1}
+	c.ChatUsernames = []string{"@alerts_channel", "@unknown_chat", "@ops_group"}
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The unknown chat is skipped, the duplicate ID is not added twice
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	var chatIds []any
+	getChatCalls := 0
+	for _, req := range s.Requests() {
+		if strings.HasSuffix(req.Path, "/sendMessage") {
+			chatIds = append(chatIds, req.Params["chat_id"])
+		}
+		if strings.HasSuffix(req.Path, "/getChat") {
+			getChatCalls++
+		}
+	}
+	require.Equal(t, []any{float64(1), float64(-1001234567890)}, chatIds)
+	require.Equal(t, 3, getChatCalls)
+	tn.UnitQuit()
+
+	// Resolved usernames are cached, the failed ones are retried on restart
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	getChatCalls = 0
+	for _, req := range s.Requests() {
+		if strings.HasSuffix(req.Path, "/getChat") {
+			getChatCalls++
+		}
+	}
+	require.Equal(t, 4, getChatCalls)
+	tn.UnitQuit()
+}
+
+func TestChatUsernamesValidation(t *testing.T) {
+	c := newTestConfig()
+	c.ChatIds = nil
+	c.ChatUsernames = []string{"@valid_name"}
+	_, err := New(t.Name(), c)
+	require.Equal(t, nil, err)
+
+	for _, username := range []string{"no_at_sign", "@abc", "@1starts_with_digit", "@bad-char"} {
+		c.ChatUsernames = []string{username}
+		_, err = New(t.Name(), c)
+		require.ErrorIs(t, err, ErrBadTelegramChatId, username)
+	}
+}
@@ -0,0 +1,58 @@
+package telegram_notifier
+
+import "time"
+
+// clock provides the current time and timers to the time-dependent
+// features: timestamps, quiet hours, message age, retry backoff,
+// deduplication windows and the circuit breaker cooldown.
+// It is replaced in tests to make them deterministic.
+type clock interface {
+	Now() time.Time
+
+	// NewTimer returns the timer whose channel receives
+	// the current time after the duration.
+	NewTimer(d time.Duration) clockTimer
+
+	// AfterFunc calls f in its own goroutine after the duration.
+	// The channel of the returned timer is not used.
+	AfterFunc(d time.Duration, f func()) clockTimer
+}
+
+// clockTimer is a timer created by clock. Stop releases the timer
+// and returns false if it has already fired or been stopped.
+type clockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// realClock is the clock used by default.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) clockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// setClock replaces the clock, must be called before UnitStart.
+func (u *TelegramNotifier) setClock(c clock) {
+	u.clock = c
+}
@@ -0,0 +1,226 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a fixed clock that can be moved by tests.
+// The timers fire when the clock is moved past their deadlines.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fire must be called with the clock locked.
+func (t *fakeTimer) fire(now time.Time) {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	t.c <- now
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	return c.addTimer(d, nil)
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	return c.addTimer(d, f)
+}
+
+func (c *fakeClock) addTimer(d time.Duration, f func()) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1), f: f}
+	if d <= 0 {
+		t.fire(c.now)
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	var pending []*fakeTimer
+	for _, timer := range c.timers {
+		if timer.at.After(t) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.fire(t)
+	}
+	c.timers = pending
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Timers returns the number of the timers that have not fired
+// or been stopped yet.
+func (c *fakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func TestClockRetryBackoff(t *testing.T) {
+	n := &fakeNotifier{failures: []error{errors.New("temporary failure")}}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.RetryBaseDelayMs = 60000
+	tn := newTestNotifier(t, c, n)
+	clock := &fakeClock{now: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	tn.setClock(clock)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Eventually(t, func() bool {
+		return clock.Timers() == 1
+	}, time.Second, time.Millisecond, "the retry must wait for the clock")
+	require.Equal(t, 1, n.Calls())
+	require.Equal(t, 0, len(n.Sent()))
+
+	// The jitter adds up to a half of the delay
+	clock.Advance(90 * time.Second)
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"title"}, sentTitles(n))
+	require.Equal(t, uint64(1), tn.Stats().Retried)
+
+	tn.UnitQuit()
+}
+
+func TestClockTimestamps(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"all"}
+	c.LogDateTime = true
+	c.LogUseUTC = true
+	c.LogTimeFormat = time.DateTime
+	tn := newTestNotifier(t, c, n)
+	tn.setClock(&fakeClock{now: time.Date(2024, 3, 5, 12, 30, 0, 0, time.UTC)})
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	tn.ForwardLog(zerolog.ErrorLevel, "disk full")
+	tn.UnitQuit()
+
+	require.Equal(t, 1, len(n.Sent()))
+	require.Contains(t, n.Sent()[0].Text, "2024-03-05 12:30:00")
+}
+
+func TestClockDedupWindow(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.DedupWindowMs = 60000
+	tn := newTestNotifier(t, c, n)
+	clock := &fakeClock{now: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	tn.setClock(clock)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("ERROR", "connection refused"))
+	require.Equal(t, nil, tn.SendAsync("ERROR", "connection refused"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, 1, clock.Timers())
+
+	// The summary is sent when the window closes by the clock
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return len(n.Sent()) == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, "connection refused\n(repeated 1 times)", n.Sent()[1].Text)
+
+	tn.UnitQuit()
+}
+
+func TestClockBreakerCooldown(t *testing.T) {
+	n := &fakeNotifier{err: errors.New("Bad Request: chat not found")}
+	c := newTestConfig()
+	c.FailureThreshold = 1
+	c.BreakerCooldownMs = 60000
+	tn := newTestNotifier(t, c, n)
+	clock := &fakeClock{now: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	tn.setClock(clock)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, BreakerStateOpen, tn.Stats().BreakerState)
+	require.Equal(t, 1, clock.Timers())
+
+	// The breaker is half-open after the cooldown by the clock
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return tn.Stats().BreakerState == BreakerStateHalfOpen
+	}, time.Second, time.Millisecond)
+
+	tn.UnitQuit()
+}
+
+func TestClockTimersStopped(t *testing.T) {
+	n := &fakeNotifier{failures: []error{errors.New("temporary failure")}}
+	c := newTestConfig()
+	c.RetryBaseDelayMs = 60000
+	tn := newTestNotifier(t, c, n)
+	clock := &fakeClock{now: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	tn.setClock(clock)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Eventually(t, func() bool {
+		return clock.Timers() == 1
+	}, time.Second, time.Millisecond, "the retry must wait for the clock")
+
+	// The retry timer interrupted by quitting is released
+	tn.UnitQuit()
+	require.Equal(t, 0, clock.Timers())
+}
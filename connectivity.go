@@ -0,0 +1,148 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Pinger is implemented by the senders that can check whether
+// their service is reachable, see Config.ConnectivityFailureThreshold.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// connectivityMonitor pauses sending the buffered messages
+// after repeated failures until the Telegram Bot API is reachable.
+type connectivityMonitor struct {
+	mu       sync.Mutex
+	failures int
+
+	// restored is not nil while connectivity is lost,
+	// it is closed when connectivity is restored.
+	restored chan struct{}
+}
+
+// connectivityLost returns true if sending is paused
+// until connectivity is restored.
+func (u *TelegramNotifier) connectivityLost() bool {
+	m := &u.connectivity
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.restored != nil
+}
+
+// recordConnectivity updates the connectivity monitor with the final result
+// of sending a message. The failures that retrying can't fix,
+// e.g. a chat that doesn't exist, are not counted.
+func (u *TelegramNotifier) recordConnectivity(err error) {
+	cfg := u.cfg()
+	if cfg.ConnectivityFailureThreshold == 0 {
+		return
+	}
+
+	m := &u.connectivity
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		m.failures = 0
+		return
+	}
+	if isPermanentSendError(err) || errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+	m.failures++
+	if m.restored != nil || m.failures < cfg.ConnectivityFailureThreshold {
+		return
+	}
+
+	m.restored = make(chan struct{})
+	u.availabilityLock.Lock()
+	if u.availabilityReason == AvailabilityReasonAvailable {
+		u.availabilityReason = AvailabilityReasonConnectivityLost
+	}
+	u.availabilityLock.Unlock()
+	u.internalLog().Warn().Int("failures", m.failures).Dur("probe_interval", cfg.ConnectivityProbeInterval).
+		Msg("connectivity lost, sends paused")
+	go u.probeConnectivity(cfg.ConnectivityProbeInterval, u.tgServiceQuitting)
+}
+
+// probeConnectivity checks periodically whether the Telegram Bot API
+// is reachable and resumes sending when it is or the unit is quitting.
+func (u *TelegramNotifier) probeConnectivity(interval time.Duration, quitting chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-quitting:
+			u.restoreConnectivity()
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), u.cfg().SendTimeout)
+		err := u.pingNotifier(ctx)
+		cancel()
+		if err == nil {
+			u.internalLog().Info().Msg("connectivity restored, sends resumed")
+			u.restoreConnectivity()
+			return
+		}
+		u.internalLog().Debug().Err(err).Msg("connectivity probe failed")
+	}
+}
+
+// pingNotifier checks whether the service of the current notifier
+// is reachable. The senders that can't be checked are considered reachable.
+func (u *TelegramNotifier) pingNotifier(ctx context.Context) error {
+	switch n := u.currentNotifier().(type) {
+	case Pinger:
+		return n.Ping(ctx)
+	case botAPIProvider:
+		return n.apiClient().getMe(ctx)
+	default:
+		return nil
+	}
+}
+
+// restoreConnectivity resumes sending, e.g. when connectivity
+// is restored or the unit starts or quits.
+func (u *TelegramNotifier) restoreConnectivity() {
+	m := &u.connectivity
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures = 0
+	if m.restored == nil {
+		return
+	}
+	close(m.restored)
+	m.restored = nil
+	u.availabilityLock.Lock()
+	if u.availabilityReason == AvailabilityReasonConnectivityLost {
+		u.availabilityReason = AvailabilityReasonAvailable
+	}
+	u.availabilityLock.Unlock()
+}
+
+// waitConnectivity blocks while sending is paused by the connectivity
+// monitor. Returns false if telegram service quit is requested.
+func (u *TelegramNotifier) waitConnectivity() bool {
+	m := &u.connectivity
+	m.mu.Lock()
+	restored := m.restored
+	m.mu.Unlock()
+	if restored == nil {
+		return true
+	}
+	select {
+	case <-restored:
+		return true
+	case <-u.tgServiceQuitting:
+		// The buffered messages are sent or fail without retries
+		return true
+	case <-u.tgServiceQuitRequest:
+		return false
+	}
+}
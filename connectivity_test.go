@@ -0,0 +1,109 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/igulib/app"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyNetwork is a fake sender that fails the sends
+// and the pings while the network is down.
+type flakyNetwork struct {
+	*fakeNotifier
+	down  atomic.Bool
+	pings atomic.Int32
+}
+
+var errNetworkDown = errors.New("dial tcp: connection refused")
+
+func (f *flakyNetwork) setDown(down bool) {
+	f.down.Store(down)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = nil
+	if down {
+		f.err = errNetworkDown
+	}
+}
+
+func (f *flakyNetwork) Ping(ctx context.Context) error {
+	f.pings.Add(1)
+	if f.down.Load() {
+		return errNetworkDown
+	}
+	return nil
+}
+
+func newConnectivityTestNotifier(t *testing.T) (*TelegramNotifier, *flakyNetwork) {
+	f := &flakyNetwork{fakeNotifier: &fakeNotifier{}}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MaxRetries = -1
+	c.ConnectivityFailureThreshold = 2
+	c.ConnectivityProbeIntervalMs = 20
+	tn := newTestNotifier(t, c, f)
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	return tn, f
+}
+
+func TestConnectivityLost(t *testing.T) {
+	tn, f := newConnectivityTestNotifier(t)
+
+	f.setDown(true)
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+	require.Eventually(t, func() bool { return tn.Stats().ConnectivityLost },
+		time.Second, time.Millisecond)
+	require.Equal(t, AvailabilityReasonConnectivityLost, tn.AvailabilityReason())
+
+	// New messages wait in the buffer, the unit remains available
+	require.Equal(t, app.UAvailable, tn.UnitAvailability())
+	require.Equal(t, nil, tn.SendAsync("3", "text"))
+	require.Equal(t, nil, tn.SendAsync("4", "text"))
+	require.Never(t, func() bool { return f.Calls() > 2 }, 100*time.Millisecond, 10*time.Millisecond)
+	require.Equal(t, 2, tn.Stats().Queued)
+	require.Greater(t, f.pings.Load(), int32(1))
+
+	// Sending resumes when the probe succeeds
+	f.setDown(false)
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"3", "4"}, sentTitles(f.fakeNotifier))
+	require.Equal(t, false, tn.Stats().ConnectivityLost)
+	require.Equal(t, AvailabilityReasonAvailable, tn.AvailabilityReason())
+	require.Equal(t, uint64(2), tn.Stats().Failed)
+
+	tn.UnitQuit()
+}
+
+func TestConnectivityLostQuit(t *testing.T) {
+	tn, f := newConnectivityTestNotifier(t)
+
+	// Permanent failures don't pause sending
+	f.failures = []error{errors.New("Bad Request: chat not found"), errors.New("Bad Request: chat not found")}
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, false, tn.Stats().ConnectivityLost)
+
+	f.setDown(true)
+	require.Equal(t, nil, tn.SendAsync("3", "text"))
+	require.Equal(t, nil, tn.SendAsync("4", "text"))
+	require.Eventually(t, func() bool { return tn.Stats().ConnectivityLost },
+		time.Second, time.Millisecond)
+	require.Equal(t, nil, tn.SendAsync("5", "text"))
+
+	// The buffered messages are not held back when the unit quits
+	f.mu.Lock()
+	f.err = nil
+	f.mu.Unlock()
+	r := tn.UnitQuit()
+	require.Equal(t, nil, r.CollateralError)
+	require.Equal(t, []string{"5"}, sentTitles(f.fakeNotifier))
+	require.Equal(t, false, tn.Stats().ConnectivityLost)
+}
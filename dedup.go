@@ -0,0 +1,106 @@
+package telegram_notifier
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// dedupMaxEntries limits the number of distinct messages tracked
+// by deduplicator, the messages exceeding the limit are not deduplicated.
+const dedupMaxEntries = 1000
+
+// deduplicator suppresses the copies of a message sent within
+// Config.DedupWindowMs after it. The zero value is ready to use.
+type deduplicator struct {
+	mu      sync.Mutex
+	entries map[uint64]*dedupEntry
+}
+
+type dedupEntry struct {
+	msg        TelegramMessage
+	suppressed int
+	timer      clockTimer
+}
+
+func dedupKey(msg TelegramMessage) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(msg.Title))
+	h.Write([]byte{0})
+	h.Write([]byte(msg.Text))
+	return h.Sum64()
+}
+
+// suppress returns true if the message is a copy of a message
+// sent within the window. Otherwise the message starts a new window,
+// when the window closes, the summary of the suppressed copies is sent.
+func (d *deduplicator) suppress(u *TelegramNotifier, msg TelegramMessage, window time.Duration) bool {
+	key := dedupKey(msg)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.entries[key]; ok {
+		e.suppressed++
+		u.tgSuppressedCounter.Add(1)
+		return true
+	}
+	if len(d.entries) >= dedupMaxEntries {
+		return false
+	}
+	if d.entries == nil {
+		d.entries = make(map[uint64]*dedupEntry)
+	}
+	e := &dedupEntry{msg: msg}
+	e.timer = u.clock.AfterFunc(window, func() {
+		d.mu.Lock()
+		// The entry may be already taken by takeAll
+		if d.entries[key] != e {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.entries, key)
+		summary, ok := e.summary()
+		d.mu.Unlock()
+
+		if ok {
+			if err := u.enqueue(summary); err != nil {
+				u.internalLog().Warn().Err(err).Msg("failed to send summary of repeated messages")
+			}
+		}
+	})
+	d.entries[key] = e
+	return false
+}
+
+// takeAll closes all windows and returns the summaries
+// of the suppressed messages.
+func (d *deduplicator) takeAll() []TelegramMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var summaries []TelegramMessage
+	for _, e := range d.entries {
+		e.timer.Stop()
+		if summary, ok := e.summary(); ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	d.entries = nil
+	return summaries
+}
+
+// summary returns the message reporting the suppressed copies, if any.
+func (e *dedupEntry) summary() (TelegramMessage, bool) {
+	if e.suppressed == 0 {
+		return TelegramMessage{}, false
+	}
+	msg := e.msg
+	// The summary must be sent even if the first message was cancelled
+	msg.ctx = context.Background()
+	msg.Text = fmt.Sprintf("%s\n(repeated %d times)", msg.Text, e.suppressed)
+	msg.dedupSummary = true
+	return msg, true
+}
@@ -0,0 +1,111 @@
+package telegram_notifier
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.DedupWindowMs = 300
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, nil, tn.SendAsync("ERROR", "connection refused"))
+	}
+	require.Equal(t, nil, tn.SendAsync("ERROR", "timeout"))
+	require.Equal(t, nil, tn.SendAsync("WARNING", "connection refused"))
+
+	require.Eventually(t, func() bool {
+		return len(n.Sent()) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var texts []string
+	for _, m := range n.Sent() {
+		texts = append(texts, m.Title+": "+m.Text)
+	}
+	require.Equal(t, []string{
+		"ERROR: connection refused",
+		"ERROR: timeout",
+		"WARNING: connection refused",
+		"ERROR: connection refused\n(repeated 4 times)",
+	}, texts)
+	require.Equal(t, uint64(4), tn.Stats().Suppressed)
+	require.Equal(t, uint64(4), tn.Stats().Sent)
+
+	// The message is sent again after the window is closed
+	require.Equal(t, nil, tn.SendAsync("ERROR", "connection refused"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, 5, len(n.Sent()))
+
+	tn.UnitQuit()
+	tn.dedup.mu.Lock()
+	require.Equal(t, 0, len(tn.dedup.entries))
+	tn.dedup.mu.Unlock()
+}
+
+func TestDedupSummaryOnQuit(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.DedupWindowMs = 60000
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, nil, tn.SendAsync("ERROR", "disk full"))
+	}
+	require.Equal(t, nil, tn.SendAsync("ERROR", "single"))
+
+	r = tn.UnitQuit()
+	require.Equal(t, nil, r.CollateralError)
+
+	var texts []string
+	for _, m := range n.Sent() {
+		texts = append(texts, m.Text)
+	}
+	require.ElementsMatch(t, []string{"disk full", "single", "disk full\n(repeated 2 times)"}, texts)
+}
+
+func TestDedupBoundedMemory(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.DedupWindowMs = 60000
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	for i := 0; i < dedupMaxEntries+10; i++ {
+		require.Equal(t, nil, tn.SendAsync("ERROR", fmt.Sprintf("error %d", i)))
+	}
+	tn.dedup.mu.Lock()
+	require.Equal(t, dedupMaxEntries, len(tn.dedup.entries))
+	tn.dedup.mu.Unlock()
+
+	// The messages exceeding the limit are not deduplicated
+	require.Equal(t, nil, tn.SendAsync("ERROR", fmt.Sprintf("error %d", dedupMaxEntries)))
+	require.Equal(t, nil, tn.SendAsync("ERROR", "error 0"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, dedupMaxEntries+11, len(n.Sent()))
+	require.Equal(t, uint64(1), tn.Stats().Suppressed)
+
+	tn.UnitQuit()
+}
+
+func TestDedupWindowValidation(t *testing.T) {
+	c := newTestConfig()
+	c.DedupWindowMs = -1
+	_, err := New(t.Name(), c)
+	require.ErrorIs(t, err, ErrBadDedupWindow)
+}
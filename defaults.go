@@ -0,0 +1,59 @@
+package telegram_notifier
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultsLock guards the defaults set by SetDefaultSendTimeout
+// and SetDefaultMsgBufSize.
+var defaultsLock sync.RWMutex
+
+var (
+	defaultSendTimeout time.Duration
+	defaultMsgBufSize  int
+)
+
+// SetDefaultSendTimeout sets the send timeout used by the units
+// created without Config.SendTimeoutSec, it is thread-safe.
+// Zero or negative d restores DefaultSendTimeoutSec.
+// Unlike assigning DefaultSendTimeoutSec, which is a data race
+// if another goroutine is creating a unit, it can be called any time.
+func SetDefaultSendTimeout(d time.Duration) {
+	defaultsLock.Lock()
+	defer defaultsLock.Unlock()
+	defaultSendTimeout = d
+}
+
+// GetDefaultSendTimeout returns the send timeout used by the units
+// created without Config.SendTimeoutSec, it is thread-safe.
+func GetDefaultSendTimeout() time.Duration {
+	defaultsLock.RLock()
+	defer defaultsLock.RUnlock()
+	if defaultSendTimeout > 0 {
+		return defaultSendTimeout
+	}
+	return time.Duration(DefaultSendTimeoutSec) * time.Second
+}
+
+// SetDefaultMsgBufSize sets the message buffer size used by the units
+// created without Config.MsgBufSize, it is thread-safe.
+// Zero or negative n restores DefaultMsgBufSize.
+// Unlike assigning DefaultMsgBufSize, which is a data race
+// if another goroutine is creating a unit, it can be called any time.
+func SetDefaultMsgBufSize(n int) {
+	defaultsLock.Lock()
+	defer defaultsLock.Unlock()
+	defaultMsgBufSize = n
+}
+
+// GetDefaultMsgBufSize returns the message buffer size used by the units
+// created without Config.MsgBufSize, it is thread-safe.
+func GetDefaultMsgBufSize() int {
+	defaultsLock.RLock()
+	defer defaultsLock.RUnlock()
+	if defaultMsgBufSize > 0 {
+		return defaultMsgBufSize
+	}
+	return DefaultMsgBufSize
+}
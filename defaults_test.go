@@ -0,0 +1,60 @@
+package telegram_notifier
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSetters(t *testing.T) {
+	t.Cleanup(func() {
+		SetDefaultSendTimeout(0)
+		SetDefaultMsgBufSize(0)
+	})
+
+	require.Equal(t, time.Duration(DefaultSendTimeoutSec)*time.Second, GetDefaultSendTimeout())
+	require.Equal(t, DefaultMsgBufSize, GetDefaultMsgBufSize())
+
+	SetDefaultSendTimeout(1500 * time.Millisecond)
+	SetDefaultMsgBufSize(7)
+	tn, err := New(t.Name(), newTestConfig())
+	require.Equal(t, nil, err)
+	require.Equal(t, 1500*time.Millisecond, tn.cfg().SendTimeout)
+	require.Equal(t, 7, cap(tn.tgMsgChan))
+
+	// The config has precedence
+	c := newTestConfig()
+	c.SendTimeoutSec = 2
+	c.MsgBufSize = 3
+	tn, err = New(t.Name()+"_config", c)
+	require.Equal(t, nil, err)
+	require.Equal(t, 2*time.Second, tn.cfg().SendTimeout)
+	require.Equal(t, 3, cap(tn.tgMsgChan))
+
+	// Concurrent use is not a data race
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			SetDefaultSendTimeout(time.Duration(i+1) * time.Second)
+			SetDefaultMsgBufSize(i + 1)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			tn, err := New(fmt.Sprintf("%s_%d", t.Name(), i), newTestConfig())
+			require.Equal(t, nil, err)
+			require.Greater(t, cap(tn.tgMsgChan), 0)
+			require.Greater(t, GetDefaultSendTimeout(), time.Duration(0))
+		}(i)
+	}
+	wg.Wait()
+
+	SetDefaultSendTimeout(0)
+	SetDefaultMsgBufSize(-1)
+	require.Equal(t, time.Duration(DefaultSendTimeoutSec)*time.Second, GetDefaultSendTimeout())
+	require.Equal(t, DefaultMsgBufSize, GetDefaultMsgBufSize())
+}
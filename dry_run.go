@@ -0,0 +1,63 @@
+package telegram_notifier
+
+import "context"
+
+// RecordedMessage is a message that would have been sent
+// to Telegram if dry run mode was disabled.
+type RecordedMessage struct {
+	Title     string
+	Text      string
+	ChatIds   []int64
+	ThreadId  int
+	ParseMode string
+	Silent    bool
+
+	// DisableWebPagePreview is the configured value
+	// unless overridden by the message options.
+	DisableWebPagePreview bool
+
+	// ProtectContent is the configured value
+	// unless overridden by the message options.
+	ProtectContent bool
+}
+
+// dryRunNotifier records the messages instead of sending them.
+type dryRunNotifier struct {
+	u *TelegramNotifier
+}
+
+func (n *dryRunNotifier) Send(ctx context.Context, subject, message string) error {
+	return n.sendMessage(ctx, TelegramMessage{Title: subject, Text: message})
+}
+
+// sendMessage implements messageOptionsSender.
+func (n *dryRunNotifier) sendMessage(ctx context.Context, msg TelegramMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n.u.recordedLock.Lock()
+	defer n.u.recordedLock.Unlock()
+	n.u.recorded = append(n.u.recorded, RecordedMessage{
+		Title:     msg.Title,
+		Text:      msg.Text,
+		ChatIds:   append([]int64(nil), msg.chatIds...),
+		ThreadId:  msg.threadId,
+		ParseMode: msg.parseMode,
+		Silent:    msg.silent,
+
+		DisableWebPagePreview: msg.disableWebPagePreview != nil && *msg.disableWebPagePreview,
+		ProtectContent:        msg.protectContent != nil && *msg.protectContent,
+	})
+	return nil
+}
+
+// RecordedMessages returns the messages recorded in dry run mode
+// in the order they were sent, it is thread-safe.
+// Long messages are recorded split into parts.
+// The messages are kept in memory until the unit is destroyed,
+// so dry run mode is not intended for production.
+func (u *TelegramNotifier) RecordedMessages() []RecordedMessage {
+	u.recordedLock.Lock()
+	defer u.recordedLock.Unlock()
+	return append([]RecordedMessage(nil), u.recorded...)
+}
@@ -0,0 +1,53 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nikoksr/notify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	c.DryRun = true
+	c.SendConcurrency = 1
+	tn, err := New(t.Name(), c)
+	require.Equal(t, nil, err)
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		return nil, errors.New("Telegram must not be used in dry run mode")
+	}
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("1", "text 1"))
+	require.Equal(t, nil, tn.SendSilent("2", "text 2"))
+	require.Equal(t, nil, tn.SendToThread(3, 4, "3", "text 3"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, nil, tn.Send(context.Background(), "4", "text 4"))
+	tn.UnitQuit()
+
+	require.Equal(t, []RecordedMessage{
+		{Title: "1", Text: "text 1", ChatIds: []int64{1, 2}},
+		{Title: "2", Text: "text 2", ChatIds: []int64{1, 2}, Silent: true},
+		{Title: "3", Text: "text 3", ChatIds: []int64{3}, ThreadId: 4},
+		{Title: "4", Text: "text 4", ChatIds: []int64{1, 2}},
+	}, tn.RecordedMessages())
+	require.Equal(t, uint64(4), tn.Stats().Sent, "recorded messages must be counted as sent")
+
+	// Dry run can be disabled by Reconfigure
+	n := &fakeNotifier{}
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		return n, nil
+	}
+	tn.UnitStart()
+	c.DryRun = false
+	require.Equal(t, nil, tn.Reconfigure(c))
+	require.Equal(t, nil, tn.Send(context.Background(), "5", "text 5"))
+	tn.UnitQuit()
+	require.Equal(t, 4, len(tn.RecordedMessages()))
+	require.Equal(t, 1, len(n.Sent()))
+}
@@ -0,0 +1,106 @@
+package telegram_notifier
+
+import "strings"
+
+// markdownV2Replacer escapes the characters reserved by Telegram MarkdownV2.
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`,
+	"_", `\_`,
+	"*", `\*`,
+	"[", `\[`,
+	"]", `\]`,
+	"(", `\(`,
+	")", `\)`,
+	"~", `\~`,
+	"`", "\\`",
+	">", `\>`,
+	"#", `\#`,
+	"+", `\+`,
+	"-", `\-`,
+	"=", `\=`,
+	"|", `\|`,
+	"{", `\{`,
+	"}", `\}`,
+	".", `\.`,
+	"!", `\!`,
+)
+
+// EscapeMarkdownV2 escapes all characters reserved by Telegram MarkdownV2
+// parse mode so that arbitrary text can be safely embedded into a message.
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Replacer.Replace(s)
+}
+
+// htmlReplacer escapes the characters reserved by Telegram HTML parse mode.
+var htmlReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+)
+
+// EscapeHTML escapes all characters reserved by Telegram HTML
+// parse mode so that arbitrary text can be safely embedded into a message.
+func EscapeHTML(s string) string {
+	return htmlReplacer.Replace(s)
+}
+
+// escapeText escapes the text according to the parse mode,
+// plain text is returned as is.
+func escapeText(s, parseMode string) string {
+	switch parseMode {
+	case ParseModeMarkdownV2:
+		return EscapeMarkdownV2(s)
+	case ParseModeHTML:
+		return EscapeHTML(s)
+	}
+	return s
+}
+
+// autoEscape escapes the message title and text according to its parse mode
+// if Config.AutoEscape is enabled and the message is not preformatted.
+// The resolved parse mode is set to the message, so that the text
+// is split without breaking the escape sequences.
+func (u *TelegramNotifier) autoEscape(msg TelegramMessage) TelegramMessage {
+	cfg := u.cfg()
+	if !cfg.AutoEscape || msg.preformatted {
+		return msg
+	}
+	if msg.parseMode == "" {
+		msg.parseMode = cfg.ParseMode
+	}
+	msg.Title = escapeText(msg.Title, msg.parseMode)
+	msg.Text = escapeText(msg.Text, msg.parseMode)
+	return msg
+}
+
+// escapeSafeCut moves the byte offset the text is cut at backwards
+// so that the escape sequence of the parse mode is not broken,
+// but never to the beginning of the text.
+func escapeSafeCut(text string, cut int, parseMode string) int {
+	switch parseMode {
+	case ParseModeMarkdownV2:
+		// An odd number of backslashes escapes the next character
+		backslashes := 0
+		for i := cut - 1; i >= 0 && text[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 && cut > 1 {
+			return cut - 1
+		}
+	case ParseModeHTML:
+		// Entities like "&quot;" are short
+		amp := strings.LastIndexByte(text[:cut], '&')
+		if amp <= 0 || cut-amp >= 10 || strings.Contains(text[amp:cut], ";") {
+			return cut
+		}
+		end := amp + 10
+		if end > len(text) {
+			end = len(text)
+		}
+		if strings.Contains(text[cut:end], ";") {
+			return amp
+		}
+	}
+	return cut
+}
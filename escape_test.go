@@ -0,0 +1,101 @@
+package telegram_notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	reserved := "_*[]()~`>#+-=|{}.!\\"
+	escaped := EscapeMarkdownV2(reserved)
+	require.Equal(t, "\\_\\*\\[\\]\\(\\)\\~\\`\\>\\#\\+\\-\\=\\|\\{\\}\\.\\!\\\\", escaped)
+
+	require.Equal(t, "plain text 123", EscapeMarkdownV2("plain text 123"))
+	require.Equal(t, "Error in foo\\_bar\\(\\): 1 \\+ 1 \\!\\= 3\\.", EscapeMarkdownV2("Error in foo_bar(): 1 + 1 != 3."))
+	require.Equal(t, "привет\\!", EscapeMarkdownV2("привет!"))
+}
+
+func TestEscapeHTML(t *testing.T) {
+	require.Equal(t, "&lt;b&gt;Tom &amp; Jerry&lt;/b&gt; &quot;quoted&quot;", EscapeHTML(`<b>Tom & Jerry</b> "quoted"`))
+	require.Equal(t, "plain text_*[]", EscapeHTML("plain text_*[]"))
+	require.Equal(t, "1 &lt; 2", EscapeHTML("1 < 2"))
+}
+
+func TestAutoEscape(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.AutoEscape = true
+	c.ParseMode = ParseModeMarkdownV2
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.Send(context.Background(), "ERROR | app", "value_1 > 2.5!"))
+	require.Equal(t, nil, tn.sendMessageSync(context.Background(), MessageOptions{
+		Title: "<b>Alert</b>", Text: "Tom & Jerry <3", ParseMode: ParseModeHTML,
+	}))
+	require.Equal(t, nil, tn.sendMessageSync(context.Background(), MessageOptions{
+		Title: "*Alert*", Text: "_already_ *formatted*", Preformatted: true,
+	}))
+	require.Equal(t, nil, tn.SendAsync("*Alert*", "async [link](x)"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 4, len(sent))
+	require.Equal(t, `ERROR \| app`, sent[0].Title)
+	require.Equal(t, `value\_1 \> 2\.5\!`, sent[0].Text)
+	require.Equal(t, "&lt;b&gt;Alert&lt;/b&gt;", sent[1].Title)
+	require.Equal(t, "Tom &amp; Jerry &lt;3", sent[1].Text)
+	require.Equal(t, "*Alert*", sent[2].Title)
+	require.Equal(t, "_already_ *formatted*", sent[2].Text)
+	require.Equal(t, `async \[link\]\(x\)`, sent[3].Text)
+
+	// Plain text is not changed
+	n = &fakeNotifier{}
+	c.ParseMode = ""
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "a_b <c>"))
+	tn.UnitQuit()
+	require.Equal(t, "a_b <c>", n.Sent()[0].Text)
+}
+
+func TestAutoEscapeBotAPICalls(t *testing.T) {
+	s := newFakeBotAPIServer(t, nil)
+	c := newTestConfig()
+	c.AutoEscape = true
+	c.ParseMode = ParseModeMarkdownV2
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	_, err := tn.SendAndGetIDs(context.Background(), "ERROR | app", "a_b")
+	require.Equal(t, nil, err)
+	require.Equal(t, nil, tn.SendWithButtons("ERROR | app", "a_b", [][]Button{{{Text: "Ack", CallbackData: "ack"}}}))
+	require.Equal(t, nil, tn.EditMessage(-1001, 42, "a_b!"))
+	_, err = tn.SendReport(context.Background(), "ERROR | app", "a_b")
+	require.Equal(t, nil, err)
+	tn.UnitQuit()
+
+	requests := s.Requests()
+	require.Equal(t, 5, len(requests))
+	for _, i := range []int{1, 2, 4} {
+		require.Equal(t, "ERROR \\| app\na\\_b", requests[i].Params["text"])
+		require.Equal(t, ParseModeMarkdownV2, requests[i].Params["parse_mode"])
+	}
+	require.Equal(t, `a\_b\!`, requests[3].Params["text"])
+	require.Equal(t, ParseModeMarkdownV2, requests[3].Params["parse_mode"])
+}
+
+func TestSplitEscapedText(t *testing.T) {
+	// Escape sequences are not broken at chunk boundaries
+	require.Equal(t, []string{"abc", `\.de`}, splitText(`abc\.de`, 4, ParseModeMarkdownV2))
+	require.Equal(t, []string{`ab\\`, "cd"}, splitText(`ab\\cd`, 4, ParseModeMarkdownV2))
+	require.Equal(t, []string{"ab", "&amp;", "cd"}, splitText("ab&amp;cd", 5, ParseModeHTML))
+	require.Equal(t, []string{"abc\\", ".de"}, splitText(`abc\.de`, 4, ""))
+}
@@ -0,0 +1,89 @@
+package telegram_notifier
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultUnitName is the unit name of the default notifier.
+const DefaultUnitName = "telegram_notifier"
+
+var (
+	defaultNotifier     *TelegramNotifier
+	defaultNotifierLock sync.Mutex
+)
+
+// Configure creates and starts the default notifier used by
+// the package-level functions, like the standard log package
+// uses the standard logger. It is intended for small programs
+// that don't use the unit manager, it is thread-safe.
+// If the default notifier is already configured, it is replaced:
+// the previous one is stopped after the messages it has accepted
+// are sent. The previous notifier is kept if the config is invalid.
+func Configure(c *Config) error {
+	return configureDefault(c, nil)
+}
+
+// configureDefault replaces the default notifier with the one
+// using the sender if not nil.
+func configureDefault(c *Config, sender MessageSender) error {
+	n, err := New(DefaultUnitName, c)
+	if err != nil {
+		return err
+	}
+	n.SetSender(sender)
+	n.UnitStart()
+
+	defaultNotifierLock.Lock()
+	prev := defaultNotifier
+	defaultNotifier = n
+	defaultNotifierLock.Unlock()
+
+	if prev != nil {
+		prev.UnitQuit()
+	}
+	return nil
+}
+
+// Default returns the default notifier or nil if it is not configured,
+// it is thread-safe.
+func Default() *TelegramNotifier {
+	defaultNotifierLock.Lock()
+	defer defaultNotifierLock.Unlock()
+	return defaultNotifier
+}
+
+// Shutdown stops the default notifier after the messages it has accepted
+// are sent, it is thread-safe. The package-level functions return
+// ErrNotConfigured until Configure is called again.
+func Shutdown() {
+	defaultNotifierLock.Lock()
+	n := defaultNotifier
+	defaultNotifier = nil
+	defaultNotifierLock.Unlock()
+
+	if n != nil {
+		n.UnitQuit()
+	}
+}
+
+// SendAsync asynchronously sends the message via the default notifier,
+// it is thread-safe. Returns ErrNotConfigured if Configure wasn't called.
+func SendAsync(title, text string) error {
+	n := Default()
+	if n == nil {
+		return ErrNotConfigured
+	}
+	return n.SendAsync(title, text)
+}
+
+// Send synchronously sends the message via the default notifier
+// and returns the actual delivery error, it is thread-safe.
+// Returns ErrNotConfigured if Configure wasn't called.
+func Send(ctx context.Context, title, text string) error {
+	n := Default()
+	if n == nil {
+		return ErrNotConfigured
+	}
+	return n.Send(ctx, title, text)
+}
@@ -0,0 +1,50 @@
+package telegram_notifier
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/igulib/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultNotifier(t *testing.T) {
+	t.Cleanup(Shutdown)
+
+	require.Equal(t, (*TelegramNotifier)(nil), Default())
+	require.ErrorIs(t, SendAsync("title", "text"), ErrNotConfigured)
+	require.ErrorIs(t, Send(context.Background(), "title", "text"), ErrNotConfigured)
+
+	n1 := &fakeNotifier{}
+	require.Equal(t, nil, configureDefault(newTestConfig(), n1))
+	first := Default()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, nil, SendAsync("async", "text"))
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, nil, Send(context.Background(), "sync", "text"))
+
+	// Invalid config keeps the current notifier
+	c := newTestConfig()
+	c.BotToken = ""
+	require.ErrorIs(t, Configure(c), ErrBadTelegramBotToken)
+	require.Equal(t, first, Default())
+
+	// Replaced notifier sends the accepted messages and stops
+	n2 := &fakeNotifier{}
+	require.Equal(t, nil, configureDefault(newTestConfig(), n2))
+	require.Equal(t, 11, len(n1.Sent()))
+	require.Equal(t, app.UNotAvailable, first.UnitAvailability())
+
+	require.Equal(t, nil, SendAsync("second", "text"))
+	Shutdown()
+	require.Equal(t, []string{"second"}, sentTitles(n2))
+	require.ErrorIs(t, SendAsync("title", "text"), ErrNotConfigured)
+}
@@ -0,0 +1,32 @@
+package telegram_notifier
+
+import "context"
+
+// sendFallback delivers the message that failed to be sent via Telegram
+// with the configured fallback services in order until one of them succeeds.
+// The message is sent as a whole even if some of its parts were delivered.
+func (u *TelegramNotifier) sendFallback(msg TelegramMessage, count uint64) {
+	cfg := u.cfg()
+	for i, s := range cfg.FallbackServices {
+		err := u.sendFallbackService(cfg, s, msg)
+		if err == nil {
+			u.tgFallbackCounter.Add(count)
+			return
+		}
+		if msg.ctx.Err() != nil {
+			return
+		}
+		u.internalLog().Error().Err(err).Int("fallback_service", i).
+			Msg("failed to send message via fallback service")
+	}
+}
+
+func (u *TelegramNotifier) sendFallbackService(cfg *validatedConfig, s MessageSender, msg TelegramMessage) error {
+	// Ongoing sends are cancelled by UnitQuit
+	abortCtx, abort := withCancelOn(msg.ctx, u.abortSignal())
+	defer abort()
+
+	ctx, cancel := context.WithTimeout(abortCtx, cfg.SendTimeout)
+	defer cancel()
+	return s.Send(ctx, msg.Title, msg.Text)
+}
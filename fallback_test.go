@@ -0,0 +1,91 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackServices(t *testing.T) {
+	telegram := &fakeNotifier{err: errors.New("Bad Request: chat not found")}
+	slow := &fakeNotifier{delay: 5 * time.Second}
+	failing := &fakeNotifier{err: errors.New("webhook failed")}
+	fallback := &fakeNotifier{}
+	c := newTestConfig()
+	c.SendTimeoutSec = 1
+	c.FallbackServices = []MessageSender{slow, failing, fallback}
+	tn := newTestNotifier(t, c, telegram)
+
+	var mu sync.Mutex
+	var callbackErrs int
+	tn.SetOnSendError(func(msg TelegramMessage, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		callbackErrs++
+	})
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The slow fallback times out, the failing one is skipped
+	start := time.Now()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Less(t, time.Since(start), 3*time.Second)
+	require.Equal(t, []string{"title"}, sentTitles(fallback))
+	require.Equal(t, 1, slow.Calls())
+	require.Equal(t, 1, failing.Calls())
+
+	// Successful sends don't use fallbacks
+	telegram.mu.Lock()
+	telegram.err = nil
+	telegram.mu.Unlock()
+	require.Equal(t, nil, tn.SendAsync("ok", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, 1, len(fallback.Sent()))
+
+	s := tn.Stats()
+	require.Equal(t, uint64(1), s.Sent)
+	require.Equal(t, uint64(1), s.Failed)
+	require.Equal(t, uint64(1), s.FallbackSent)
+	mu.Lock()
+	require.Equal(t, 1, callbackErrs)
+	mu.Unlock()
+
+	tn.UnitQuit()
+}
+
+func TestFallbackServicesCircuitOpen(t *testing.T) {
+	telegram := &fakeNotifier{err: errors.New("Bad Request: chat not found"), delay: 50 * time.Millisecond}
+	fallback := &fakeNotifier{}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.FailureThreshold = 1
+	WithFallbackServices(fallback)(c)
+	tn := newTestNotifier(t, c, telegram)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The messages failed fast by the open breaker are delivered as well
+	for i := 0; i < 3; i++ {
+		require.Equal(t, nil, tn.SendAsync("title", "text"))
+	}
+	require.Eventually(t, func() bool {
+		return tn.Stats().FallbackSent == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, telegram.Calls())
+
+	tn.UnitQuit()
+}
+
+func TestFallbackServicesValidation(t *testing.T) {
+	c := newTestConfig()
+	c.FallbackServices = []MessageSender{&fakeNotifier{}, nil}
+	_, err := New(t.Name(), c)
+	require.ErrorIs(t, err, ErrBadFallbackService)
+}
@@ -0,0 +1,62 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SendLocation synchronously sends the point on the map to the configured
+// chats, it is thread-safe. Latitude must be within -90..90 and longitude
+// within -180..180 degrees, otherwise ErrBadLocation is returned.
+// Telegram locations have no caption, so the title, if not empty,
+// is sent as a message the location replies to.
+// A failure to deliver to one chat doesn't prevent delivery to the others,
+// the errors are returned joined as *SendError.
+func (u *TelegramNotifier) SendLocation(lat, lon float64, title string) error {
+	var errs []error
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		errs = append(errs, fmt.Errorf("%w: latitude %v is out of range -90..90", ErrBadLocation, lat))
+	}
+	if math.IsNaN(lon) || lon < -180 || lon > 180 {
+		errs = append(errs, fmt.Errorf("%w: longitude %v is out of range -180..180", ErrBadLocation, lon))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	cfg := u.cfg()
+	chatIds := u.mutedChats.unmuted(cfg.ChatIds)
+	return u.callAPI(context.Background(), chatIds, func(ctx context.Context, api botAPIProvider) error {
+		var errs []error
+		for _, chatId := range chatIds {
+			p := &sendLocationParams{
+				ChatId:          chatId,
+				MessageThreadId: api.threadId(chatId),
+				Latitude:        lat,
+				Longitude:       lon,
+				ProtectContent:  cfg.ProtectContent,
+			}
+			if title != "" {
+				id, err := api.apiClient().sendMessage(ctx, &sendMessageParams{
+					ChatId:            chatId,
+					MessageThreadId:   p.MessageThreadId,
+					Text:              title,
+					ParseMode:         cfg.ParseMode,
+					DisableWebPreview: cfg.DisableWebPagePreview,
+					ProtectContent:    cfg.ProtectContent,
+				})
+				if err != nil {
+					errs = append(errs, newSendError(chatId, err))
+					continue
+				}
+				p.ReplyToMessageId = id
+			}
+			if err := api.apiClient().sendLocation(ctx, p); err != nil {
+				errs = append(errs, newSendError(chatId, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
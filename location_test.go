@@ -0,0 +1,59 @@
+package telegram_notifier
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendLocation(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		if strings.HasSuffix(r.Path, "/sendMessage") {
+			return 200, `{"ok":true,"result":{"message_id":7}}`
+		}
+		return 200, `{"ok":true,"result":{}}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendLocation(52.52, 13.405, ""))
+	requests := s.Requests()
+	require.Equal(t, 3, len(requests))
+	for i, chatId := range []float64{1, 2} {
+		req := requests[1+i]
+		require.True(t, strings.HasSuffix(req.Path, "/sendLocation"))
+		require.Equal(t, chatId, req.Params["chat_id"])
+		require.Equal(t, 52.52, req.Params["latitude"])
+		require.Equal(t, 13.405, req.Params["longitude"])
+		require.Equal(t, nil, req.Params["reply_to_message_id"])
+	}
+
+	// The location replies to the title
+	require.Equal(t, nil, tn.SendLocation(-33.8688, 151.2093, "Server room"))
+	requests = s.Requests()
+	require.Equal(t, 7, len(requests))
+	require.True(t, strings.HasSuffix(requests[3].Path, "/sendMessage"))
+	require.Equal(t, "Server room", requests[3].Params["text"])
+	require.True(t, strings.HasSuffix(requests[4].Path, "/sendLocation"))
+	require.Equal(t, -33.8688, requests[4].Params["latitude"])
+	require.Equal(t, 151.2093, requests[4].Params["longitude"])
+	require.Equal(t, float64(7), requests[4].Params["reply_to_message_id"])
+
+	// Out-of-range coordinates are rejected before sending
+	for _, p := range [][2]float64{{91, 0}, {-90.5, 0}, {0, 180.1}, {0, -181}, {math.NaN(), 0}} {
+		err := tn.SendLocation(p[0], p[1], "")
+		require.ErrorIs(t, err, ErrBadLocation, p)
+	}
+	err := tn.SendLocation(100, 200, "")
+	require.Contains(t, err.Error(), "latitude 100")
+	require.Contains(t, err.Error(), "longitude 200")
+	require.Equal(t, 7, len(s.Requests()))
+
+	tn.UnitQuit()
+}
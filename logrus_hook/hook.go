@@ -0,0 +1,86 @@
+// Package logrus_hook forwards logrus log entries to Telegram
+// via TelegramNotifier. It is a separate package so that
+// the telegram_notifier package doesn't depend on logrus.
+package logrus_hook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/sirupsen/logrus"
+)
+
+// Target is implemented by *telegram_notifier.TelegramNotifier.
+type Target interface {
+	ForwardLog(level zerolog.Level, message string)
+	AcceptsLogLevel(level zerolog.Level) bool
+}
+
+// Hook implements logrus.Hook. The entries having the log levels
+// and prefixes configured for the TelegramNotifier are sent
+// asynchronously, so logging is not blocked.
+type Hook struct {
+	target Target
+}
+
+// New creates a Hook that forwards the log entries to the target.
+func New(target Target) *Hook {
+	return &Hook{target: target}
+}
+
+// levelToZerolog maps logrus levels to zerolog levels.
+func levelToZerolog(level logrus.Level) zerolog.Level {
+	switch level {
+	case logrus.PanicLevel:
+		return zerolog.PanicLevel
+	case logrus.FatalLevel:
+		return zerolog.FatalLevel
+	case logrus.ErrorLevel:
+		return zerolog.ErrorLevel
+	case logrus.WarnLevel:
+		return zerolog.WarnLevel
+	case logrus.InfoLevel:
+		return zerolog.InfoLevel
+	case logrus.DebugLevel:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
+
+// Levels implements logrus.Hook. All levels are returned
+// because the log levels of the target can be changed at runtime.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook. The entry fields are appended
+// to the message as key=value pairs sorted by key.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	level := levelToZerolog(e.Level)
+	if !h.target.AcceptsLogLevel(level) {
+		return nil
+	}
+	h.target.ForwardLog(level, formatEntry(e))
+	return nil
+}
+
+func formatEntry(e *logrus.Entry) string {
+	if len(e.Data) == 0 {
+		return e.Message
+	}
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, e.Data[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package logrus_hook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igulib/telegram_notifier"
+)
+
+// fakeSender records the sent messages.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *fakeSender) Send(ctx context.Context, subject, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, subject+": "+message)
+	return nil
+}
+
+func TestHook(t *testing.T) {
+	tn, err := telegram_notifier.New(t.Name(), &telegram_notifier.Config{
+		BotToken:            "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:             []int64{1},
+		LogLevels:           []string{">=warning"},
+		LogOnlyWithPrefixes: []string{"[tg]"},
+		StripMatchedPrefix:  true,
+		MaxMessagesPerSec:   -1,
+		SendConcurrency:     1,
+	})
+	require.Equal(t, nil, err)
+	s := &fakeSender{}
+	tn.SetSender(s)
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(New(tn))
+
+	logger.Info("[tg] info is not forwarded")
+	logger.Error("no prefix is not forwarded")
+	logger.WithFields(logrus.Fields{"disk": "/dev/sda", "free": 0}).Warn("[tg] disk full")
+	logger.WithError(errors.New("timeout")).Error("[tg] request failed")
+
+	tn.UnitQuit()
+
+	require.Equal(t, []string{
+		"WARNING: disk full disk=/dev/sda free=0",
+		"ERROR: request failed error=timeout",
+	}, s.sent)
+}
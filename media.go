@@ -0,0 +1,188 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/igulib/app"
+)
+
+// MaxDocumentSize is the maximum size of a document
+// that can be sent via Telegram Bot API.
+const MaxDocumentSize = 50 << 20
+
+// MaxPhotoSize is the maximum size of a photo
+// that can be sent via Telegram Bot API.
+const MaxPhotoSize = 10 << 20
+
+// botAPIProvider is implemented by the notifiers that send messages
+// via Telegram Bot API. It allows using the Bot API methods not supported
+// by notify.Notifier, e.g. sending files.
+type botAPIProvider interface {
+	apiClient() *botAPI
+	threadId(chatId int64) int
+}
+
+// callAPI calls f with the Bot API client of the current notifier
+// within the same limits as the messages are sent: the rate limits,
+// send timeout and shutdown timeout. The send timeout applies
+// to each Bot API call made by f, e.g. each upload. The call is counted as a sent
+// or failed message. Returns ErrNotSupported if the current notifier
+// doesn't use Bot API, e.g. in dry run mode or with a custom sender.
+func (u *TelegramNotifier) callAPI(ctx context.Context, chatIds []int64,
+	f func(ctx context.Context, api botAPIProvider) error) error {
+	u.availabilityLock.Lock()
+	if u.availability != app.UAvailable {
+		u.availabilityLock.Unlock()
+		return ErrUnitNotAvailable
+	}
+	u.addRequest()
+	u.availabilityLock.Unlock()
+	defer u.doneRequest()
+
+	// Wait until telegram service is initialized
+	select {
+	case <-u.tgServiceReady:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	notifier := u.currentNotifier()
+	if notifier == nil {
+		return ErrUnitNotAvailable
+	}
+	api, ok := notifier.(botAPIProvider)
+	if !ok {
+		return ErrNotSupported
+	}
+	if !u.breakerAllows() {
+		u.tgFailedCounter.Add(1)
+		return ErrCircuitOpen
+	}
+
+	// Ongoing calls are cancelled by UnitQuit
+	abortCtx, abort := withCancelOn(ctx, u.abortSignal())
+	defer abort()
+
+	if err := u.rateLimiter.Load().Wait(abortCtx, chatIds); err != nil {
+		return err
+	}
+
+	start := u.clock.Now()
+	err := f(abortCtx, api)
+	u.observeSendDuration(u.clock.Now().Sub(start))
+
+	if err == nil {
+		u.tgSentCounter.Add(1)
+		u.recordSendResult(nil)
+	} else if ctx.Err() == nil {
+		// Cancellation by the sender is not a failure
+		u.tgFailedCounter.Add(1)
+		u.recordSendResult(err)
+	}
+	return err
+}
+
+// SendDocument synchronously sends the file with the specified name
+// and caption to the configured chats, it is thread-safe.
+// The file is read into memory, files larger than MaxDocumentSize
+// are rejected with ErrFileTooLarge. The file is uploaded once
+// and then sent to other chats by its Telegram file ID.
+// The configured parse mode is applied to the caption.
+// A failure to deliver to one chat doesn't prevent delivery to the others,
+// the errors are returned joined as *SendError.
+func (u *TelegramNotifier) SendDocument(caption string, filename string, r io.Reader) error {
+	data, err := readFile(filename, r, MaxDocumentSize)
+	if err != nil {
+		return err
+	}
+	return u.uploadFile(sendDocumentMethod, caption, filename, data)
+}
+
+// SendPhoto synchronously sends the photo with the specified caption
+// to the configured chats, it is thread-safe. The photo must not be empty
+// or larger than MaxPhotoSize. Otherwise it works the same way as SendDocument.
+func (u *TelegramNotifier) SendPhoto(caption string, r io.Reader) error {
+	data, err := readFile("photo", r, MaxPhotoSize)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("%w: photo", ErrEmptyFile)
+	}
+	return u.uploadFile(sendPhotoMethod, caption, "photo", data)
+}
+
+// SendPhotoURL synchronously sends the photo available by the HTTP(S) URL
+// with the specified caption to the configured chats, it is thread-safe.
+// Telegram downloads the photo itself. The configured parse mode
+// is applied to the caption. A failure to deliver to one chat doesn't
+// prevent delivery to the others, the errors are returned joined as *SendError.
+func (u *TelegramNotifier) SendPhotoURL(caption, photoURL string) error {
+	parsed, err := url.Parse(photoURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %q", ErrBadPhotoURL, photoURL)
+	}
+
+	cfg := u.cfg()
+	chatIds := u.mutedChats.unmuted(cfg.ChatIds)
+	return u.callAPI(context.Background(), chatIds, func(ctx context.Context, api botAPIProvider) error {
+		var errs []error
+		for _, chatId := range chatIds {
+			p := newMediaParams(cfg, api, chatId, caption)
+			if err := api.apiClient().sendFileByRef(ctx, sendPhotoMethod, p, photoURL); err != nil {
+				errs = append(errs, newSendError(chatId, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// readFile reads the file into memory checking its size.
+func readFile(filename string, r io.Reader, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: %q exceeds %d bytes", ErrFileTooLarge, filename, maxSize)
+	}
+	return data, nil
+}
+
+func newMediaParams(cfg *validatedConfig, api botAPIProvider, chatId int64, caption string) *mediaParams {
+	return &mediaParams{
+		ChatId:          chatId,
+		MessageThreadId: api.threadId(chatId),
+		Caption:         caption,
+		ParseMode:       cfg.ParseMode,
+		ProtectContent:  cfg.ProtectContent,
+	}
+}
+
+// uploadFile sends the file to the configured chats uploading it once
+// and then sending it to other chats by its Telegram file ID.
+func (u *TelegramNotifier) uploadFile(m mediaMethod, caption, filename string, data []byte) error {
+	cfg := u.cfg()
+	chatIds := u.mutedChats.unmuted(cfg.ChatIds)
+	return u.callAPI(context.Background(), chatIds, func(ctx context.Context, api botAPIProvider) error {
+		var fileId string
+		var errs []error
+		for _, chatId := range chatIds {
+			p := newMediaParams(cfg, api, chatId, caption)
+			var err error
+			if fileId == "" {
+				fileId, err = api.apiClient().uploadFile(ctx, m, p, filename, data)
+			} else {
+				err = api.apiClient().sendFileByRef(ctx, m, p, fileId)
+			}
+			if err != nil {
+				errs = append(errs, newSendError(chatId, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
@@ -0,0 +1,157 @@
+package telegram_notifier
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newBotAPITestNotifier creates a TelegramNotifier that sends messages
+// via the fake Bot API server.
+func newBotAPITestNotifier(t *testing.T, s *fakeBotAPIServer, c *Config) *TelegramNotifier {
+	c.ApiBaseURL = s.URL
+	tn, err := New(t.Name(), c)
+	require.Equal(t, nil, err, "telegram_notifier must be created successfully")
+	return tn
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestSendDocument(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		if _, ok := r.Params["document"].(botAPIFile); ok {
+			if r.Params["chat_id"] == "1" {
+				return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`
+			}
+			return http.StatusOK, `{"ok":true,"result":{"message_id":10,"document":{"file_id":"file-1"}}}`
+		}
+		return http.StatusOK, `{"ok":true,"result":{}}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2, 3}
+	c.ChatThreads = map[int64]int{3: 7}
+	c.ParseMode = ParseModeHTML
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	err := tn.SendDocument("<b>report</b>", "report.csv", strings.NewReader("a,b\n1,2\n"))
+	var sendErr *SendError
+	require.Equal(t, true, errors.As(err, &sendErr))
+	require.Equal(t, int64(1), sendErr.ChatId)
+	require.Equal(t, false, sendErr.Retryable)
+
+	// The file is uploaded once and then sent by file ID
+	requests := s.Requests()
+	require.Equal(t, 4, len(requests))
+	require.True(t, strings.HasSuffix(requests[0].Path, "/getMe"))
+	for _, req := range requests[1:] {
+		require.True(t, strings.HasSuffix(req.Path, "/sendDocument"))
+		require.Equal(t, "<b>report</b>", req.Params["caption"])
+		require.Equal(t, ParseModeHTML, req.Params["parse_mode"])
+	}
+	file := botAPIFile{Name: "report.csv", Data: "a,b\n1,2\n"}
+	require.Equal(t, file, requests[1].Params["document"])
+	require.Equal(t, file, requests[2].Params["document"])
+	require.Equal(t, "2", requests[2].Params["chat_id"])
+	require.Equal(t, "file-1", requests[3].Params["document"])
+	require.Equal(t, float64(3), requests[3].Params["chat_id"])
+	require.Equal(t, float64(7), requests[3].Params["message_thread_id"])
+
+	// Too large files are rejected before sending
+	err = tn.SendDocument("", "huge.bin", io.LimitReader(zeroReader{}, MaxDocumentSize+1))
+	require.ErrorIs(t, err, ErrFileTooLarge)
+	require.Equal(t, 4, len(s.Requests()))
+
+	tn.UnitQuit()
+	require.ErrorIs(t, tn.SendDocument("", "report.csv", strings.NewReader("a")), ErrUnitNotAvailable)
+}
+
+func TestSendDocumentNotSupported(t *testing.T) {
+	tn := newTestNotifier(t, newTestConfig(), &fakeNotifier{})
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	err := tn.SendDocument("", "report.csv", strings.NewReader("a"))
+	require.ErrorIs(t, err, ErrNotSupported)
+
+	tn.UnitQuit()
+}
+
+func TestSendPhoto(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		if _, ok := r.Params["photo"].(botAPIFile); ok {
+			return http.StatusOK, `{"ok":true,"result":{"message_id":10,"photo":[{"file_id":"small"},{"file_id":"large"}]}}`
+		}
+		return http.StatusOK, `{"ok":true,"result":{}}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	c.ParseMode = ParseModeMarkdownV2
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendPhoto("*chart*", strings.NewReader("png data")))
+	require.Equal(t, nil, tn.SendPhotoURL("*chart*", "https://example.com/chart.png"))
+
+	requests := s.Requests()
+	require.Equal(t, 5, len(requests))
+	for _, req := range requests[1:] {
+		require.True(t, strings.HasSuffix(req.Path, "/sendPhoto"))
+		require.Equal(t, "*chart*", req.Params["caption"])
+		require.Equal(t, ParseModeMarkdownV2, req.Params["parse_mode"])
+	}
+	require.Equal(t, botAPIFile{Name: "photo", Data: "png data"}, requests[1].Params["photo"])
+	require.Equal(t, "large", requests[2].Params["photo"], "the largest photo size must be reused")
+	require.Equal(t, float64(2), requests[2].Params["chat_id"])
+	require.Equal(t, "https://example.com/chart.png", requests[3].Params["photo"])
+	require.Equal(t, float64(1), requests[3].Params["chat_id"])
+	require.Equal(t, "https://example.com/chart.png", requests[4].Params["photo"])
+
+	// Invalid photos are rejected before sending
+	require.ErrorIs(t, tn.SendPhoto("", strings.NewReader("")), ErrEmptyFile)
+	require.ErrorIs(t, tn.SendPhoto("", io.LimitReader(zeroReader{}, MaxPhotoSize+1)), ErrFileTooLarge)
+	for _, bad := range []string{"", "chart.png", "ftp://example.com/chart.png", "https://", "http://exa mple.com"} {
+		require.ErrorIs(t, tn.SendPhotoURL("", bad), ErrBadPhotoURL, bad)
+	}
+	require.Equal(t, 5, len(s.Requests()))
+
+	tn.UnitQuit()
+}
+
+func TestSendTimeoutPerAPICall(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		if r.Params["document"] != nil {
+			time.Sleep(600 * time.Millisecond)
+			return http.StatusOK, `{"ok":true,"result":{"message_id":10,"document":{"file_id":"file-1"}}}`
+		}
+		return http.StatusOK, `{"ok":true,"result":{}}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2, 3}
+	c.SendTimeoutSec = 1
+	tn := newBotAPITestNotifier(t, s, c)
+	tn.UnitStart()
+	defer tn.UnitQuit()
+
+	// Each call fits into the timeout, all of them together don't
+	start := time.Now()
+	require.Equal(t, nil, tn.SendDocument("caption", "report.txt", strings.NewReader("data")))
+	require.GreaterOrEqual(t, time.Since(start), 1500*time.Millisecond)
+}
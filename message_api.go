@@ -0,0 +1,207 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// messageDeleteWindow is the time Telegram allows deleting
+// the messages sent by the bot.
+const messageDeleteWindow = 48 * time.Hour
+
+// sentMessagesRetention is the time the send times of the messages
+// sent by SendAndGetIDs are remembered.
+const sentMessagesRetention = 7 * 24 * time.Hour
+
+type sentMessageKey struct {
+	chatId    int64
+	messageId int
+}
+
+// sentMessages remembers when the messages returned by SendAndGetIDs
+// were sent, so that DeleteMessage can tell whether the message is too old.
+type sentMessages struct {
+	mu        sync.Mutex
+	sentAt    map[sentMessageKey]time.Time
+	lastPrune time.Time
+}
+
+func (s *sentMessages) add(chatId int64, messageId int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sentAt == nil {
+		s.sentAt = make(map[sentMessageKey]time.Time)
+	}
+	// Forget the expired messages at most once an hour
+	if now.Sub(s.lastPrune) >= time.Hour {
+		for k, at := range s.sentAt {
+			if now.Sub(at) >= sentMessagesRetention {
+				delete(s.sentAt, k)
+			}
+		}
+		s.lastPrune = now
+	}
+	s.sentAt[sentMessageKey{chatId, messageId}] = now
+}
+
+// tooOldToDelete returns true if the message is known
+// to be sent before the delete window.
+func (s *sentMessages) tooOldToDelete(chatId int64, messageId int, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at, ok := s.sentAt[sentMessageKey{chatId, messageId}]
+	return ok && now.Sub(at) >= messageDeleteWindow
+}
+
+// SendAndGetIDs synchronously sends the message to the configured chats
+// and returns the IDs of the sent messages by chat ID, it is thread-safe.
+// The IDs can be used to edit or delete the messages later.
+// Unlike Send, the message is not split, so it must not exceed
+// MaxMessageLength.
+// A failure to deliver to one chat doesn't prevent delivery to the others:
+// the IDs of the delivered messages are returned along with
+// the errors joined as *SendError.
+func (u *TelegramNotifier) SendAndGetIDs(ctx context.Context, title, text string) (map[int64]int, error) {
+	cfg := u.cfg()
+	chatIds := u.mutedChats.unmuted(cfg.ChatIds)
+	msg := u.autoEscape(TelegramMessage{Title: title, Text: text, parseMode: cfg.ParseMode})
+	ids := make(map[int64]int)
+	err := u.callAPI(ctx, chatIds, func(ctx context.Context, api botAPIProvider) error {
+		var errs []error
+		for _, chatId := range chatIds {
+			id, err := api.apiClient().sendMessage(ctx, &sendMessageParams{
+				ChatId:            chatId,
+				MessageThreadId:   api.threadId(chatId),
+				Text:              composeText(msg.Title, msg.Text, cfg.TitleMode),
+				ParseMode:         msg.parseMode,
+				DisableWebPreview: cfg.DisableWebPagePreview,
+				ProtectContent:    cfg.ProtectContent,
+			})
+			if err != nil {
+				errs = append(errs, newSendError(chatId, err))
+				continue
+			}
+			ids[chatId] = id
+			u.sentMessages.add(chatId, id, u.clock.Now())
+		}
+		return errors.Join(errs...)
+	})
+	return ids, err
+}
+
+// DeliveryReport is the per-chat outcome of SendReport.
+type DeliveryReport struct {
+	// PerChat maps each configured chat ID to the error of sending
+	// the message to the chat, nil if the message is delivered.
+	PerChat map[int64]error
+
+	// Delivered is the number of chats the message is delivered to.
+	Delivered int
+
+	// Failed is the number of chats the message failed to be delivered to.
+	Failed int
+}
+
+// SendReport synchronously sends the message to each configured chat
+// and reports the outcome per chat, it is thread-safe.
+// The message is split or truncated like the messages sent with Send.
+// The returned error is not nil only if the message is delivered
+// to none of the chats, the per-chat errors are *SendError.
+func (u *TelegramNotifier) SendReport(ctx context.Context, title, text string) (DeliveryReport, error) {
+	cfg := u.cfg()
+	chatIds := u.mutedChats.unmuted(cfg.ChatIds)
+	report := DeliveryReport{PerChat: make(map[int64]error, len(chatIds))}
+	parts := cfg.fitMessage(u.autoEscape(TelegramMessage{Title: title, Text: text}))
+	err := u.callAPI(ctx, chatIds, func(ctx context.Context, api botAPIProvider) error {
+		var errs []error
+		for _, chatId := range chatIds {
+			var chatErr error
+			for _, part := range parts {
+				parseMode := part.parseMode
+				if parseMode == "" {
+					parseMode = cfg.ParseMode
+				}
+				_, err := api.apiClient().sendMessage(ctx, &sendMessageParams{
+					ChatId:            chatId,
+					MessageThreadId:   api.threadId(chatId),
+					Text:              composeText(part.Title, part.Text, cfg.TitleMode),
+					ParseMode:         parseMode,
+					DisableWebPreview: cfg.DisableWebPagePreview,
+					ProtectContent:    cfg.ProtectContent,
+				})
+				if err != nil {
+					chatErr = newSendError(chatId, err)
+					errs = append(errs, chatErr)
+					break
+				}
+			}
+			report.PerChat[chatId] = chatErr
+		}
+		return errors.Join(errs...)
+	})
+	if err != nil && len(report.PerChat) == 0 {
+		// Nothing is sent, e.g. the unit is not available
+		for _, chatId := range chatIds {
+			report.PerChat[chatId] = err
+		}
+	}
+	for _, chatErr := range report.PerChat {
+		if chatErr == nil {
+			report.Delivered++
+		} else {
+			report.Failed++
+		}
+	}
+	if report.Delivered > 0 {
+		return report, nil
+	}
+	return report, err
+}
+
+// EditMessage synchronously replaces the text of the message
+// sent by SendAndGetIDs, it is thread-safe. The configured parse mode
+// and Config.AutoEscape are applied to the new text. Editing the message
+// without changing its text is not an error.
+func (u *TelegramNotifier) EditMessage(chatId int64, messageId int, newText string) error {
+	cfg := u.cfg()
+	msg := u.autoEscape(TelegramMessage{Text: newText, parseMode: cfg.ParseMode})
+	return u.callAPI(context.Background(), []int64{chatId}, func(ctx context.Context, api botAPIProvider) error {
+		err := api.apiClient().editMessageText(ctx, &editMessageTextParams{
+			ChatId:    chatId,
+			MessageId: messageId,
+			Text:      msg.Text,
+			ParseMode: msg.parseMode,
+		})
+		if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+			return newSendError(chatId, err)
+		}
+		return nil
+	})
+}
+
+// DeleteMessage synchronously deletes the message sent by SendAndGetIDs,
+// it is thread-safe. Telegram doesn't allow deleting messages sent
+// more than 48 hours ago, in this case the returned error wraps
+// ErrMessageTooOld if the message was sent by this unit within
+// the last week. Otherwise the Bot API error is returned as is,
+// e.g. when the bot lacks the rights to delete the message.
+func (u *TelegramNotifier) DeleteMessage(chatId int64, messageId int) error {
+	return u.callAPI(context.Background(), []int64{chatId}, func(ctx context.Context, api botAPIProvider) error {
+		err := api.apiClient().deleteMessage(ctx, &deleteMessageParams{
+			ChatId:    chatId,
+			MessageId: messageId,
+		})
+		if err != nil && strings.Contains(err.Error(), "message can't be deleted") &&
+			u.sentMessages.tooOldToDelete(chatId, messageId, u.clock.Now()) {
+			err = fmt.Errorf("%w: %v", ErrMessageTooOld, err)
+		}
+		if err != nil {
+			return newSendError(chatId, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,183 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendAndGetIDs(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		switch r.Params["chat_id"] {
+		case nil:
+			return http.StatusOK, `{"ok":true,"result":{}}`
+		case float64(2):
+			return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`
+		}
+		return http.StatusOK, fmt.Sprintf(`{"ok":true,"result":{"message_id":%v}}`, r.Params["chat_id"].(float64)*100)
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2, 3}
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	ids, err := tn.SendAndGetIDs(context.Background(), "title", "text")
+	require.Equal(t, map[int64]int{1: 100, 3: 300}, ids)
+	var sendErr *SendError
+	require.Equal(t, true, errors.As(err, &sendErr))
+	require.Equal(t, int64(2), sendErr.ChatId)
+
+	requests := s.Requests()
+	require.Equal(t, 4, len(requests))
+	require.Equal(t, "title\ntext", requests[1].Params["text"])
+
+	tn.UnitQuit()
+}
+
+func TestSendReport(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		switch r.Params["chat_id"] {
+		case float64(2), float64(4):
+			return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`
+		}
+		return http.StatusOK, `{"ok":true,"result":{}}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2, 3}
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	report, err := tn.SendReport(context.Background(), "title", "text")
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, report.Delivered)
+	require.Equal(t, 1, report.Failed)
+	require.Equal(t, 3, len(report.PerChat))
+	require.Equal(t, nil, report.PerChat[1])
+	require.Equal(t, nil, report.PerChat[3])
+	var sendErr *SendError
+	require.Equal(t, true, errors.As(report.PerChat[2], &sendErr))
+	require.Equal(t, int64(2), sendErr.ChatId)
+	require.Equal(t, false, sendErr.Retryable)
+
+	requests := s.Requests()
+	require.Equal(t, 4, len(requests))
+	require.Equal(t, "title\ntext", requests[1].Params["text"])
+
+	tn.UnitQuit()
+
+	// The error is returned if all chats fail
+	c.ChatIds = []int64{2, 4}
+	tn = newBotAPITestNotifier(t, s, c)
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	report, err = tn.SendReport(context.Background(), "title", "text")
+	require.Equal(t, true, errors.As(err, &sendErr))
+	require.Equal(t, 0, report.Delivered)
+	require.Equal(t, 2, report.Failed)
+	tn.UnitQuit()
+
+	// All chats fail if the unit is not available
+	report, err = tn.SendReport(context.Background(), "title", "text")
+	require.ErrorIs(t, err, ErrUnitNotAvailable)
+	require.Equal(t, map[int64]error{2: ErrUnitNotAvailable, 4: ErrUnitNotAvailable}, report.PerChat)
+	require.Equal(t, 2, report.Failed)
+}
+
+func TestEditMessage(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		switch r.Params["text"] {
+		case "same":
+			return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message"}`
+		case "gone":
+			return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`
+		}
+		return http.StatusOK, `{"ok":true,"result":{}}`
+	})
+	c := newTestConfig()
+	c.ParseMode = ParseModeHTML
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.EditMessage(-1001, 42, "<b>deploy complete</b>"))
+	require.Equal(t, nil, tn.EditMessage(-1001, 42, "same"))
+
+	err := tn.EditMessage(-1001, 43, "gone")
+	var sendErr *SendError
+	require.Equal(t, true, errors.As(err, &sendErr))
+	require.Equal(t, int64(-1001), sendErr.ChatId)
+
+	requests := s.Requests()
+	require.Equal(t, 4, len(requests))
+	require.Equal(t, "/bot"+c.BotToken+"/editMessageText", requests[1].Path)
+	require.Equal(t, map[string]any{
+		"chat_id":    float64(-1001),
+		"message_id": float64(42),
+		"text":       "<b>deploy complete</b>",
+		"parse_mode": ParseModeHTML,
+	}, requests[1].Params)
+
+	tn.UnitQuit()
+}
+
+func TestDeleteMessage(t *testing.T) {
+	s := newFakeBotAPIServer(t, func(r botAPIRequest) (int, string) {
+		if r.Params["text"] != nil {
+			return http.StatusOK, `{"ok":true,"result":{"message_id":1}}`
+		}
+		if r.Params["message_id"] == float64(1) || r.Params["message_id"] == float64(2) {
+			return http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: message can't be deleted"}`
+		}
+		return http.StatusOK, `{"ok":true,"result":true}`
+	})
+	c := newTestConfig()
+	c.ChatIds = []int64{-1001}
+	tn := newBotAPITestNotifier(t, s, c)
+	clock := &fakeClock{now: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	tn.setClock(clock)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.DeleteMessage(-1001, 42))
+	requests := s.Requests()
+	require.Equal(t, 2, len(requests))
+	require.Equal(t, "/bot"+c.BotToken+"/deleteMessage", requests[1].Path)
+	require.Equal(t, map[string]any{"chat_id": float64(-1001), "message_id": float64(42)}, requests[1].Params)
+
+	ids, err := tn.SendAndGetIDs(context.Background(), "title", "text")
+	require.Equal(t, nil, err)
+	require.Equal(t, map[int64]int{-1001: 1}, ids)
+
+	// The message sent recently can't be deleted, e.g. due to missing rights
+	clock.Advance(time.Hour)
+	err = tn.DeleteMessage(-1001, 1)
+	require.NotErrorIs(t, err, ErrMessageTooOld)
+	require.ErrorContains(t, err, "message can't be deleted")
+
+	// The message is known to be too old
+	clock.Advance(48 * time.Hour)
+	err = tn.DeleteMessage(-1001, 1)
+	require.ErrorIs(t, err, ErrMessageTooOld)
+	var sendErr *SendError
+	require.Equal(t, true, errors.As(err, &sendErr))
+	require.Equal(t, int64(-1001), sendErr.ChatId)
+	require.Equal(t, false, sendErr.Retryable)
+
+	// The age of the messages not sent by SendAndGetIDs is unknown
+	err = tn.DeleteMessage(-1001, 2)
+	require.NotErrorIs(t, err, ErrMessageTooOld)
+	require.Equal(t, true, errors.As(err, &sendErr))
+
+	tn.UnitQuit()
+}
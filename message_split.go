@@ -0,0 +1,148 @@
+package telegram_notifier
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// splitMessage splits the message whose text composed with the title
+// according to the title mode exceeds maxLen characters into several
+// messages, breaking the text at line boundaries where possible.
+// Each part is marked with "(part N/M)" escaped for the parse mode:
+// the marker is appended to the title, or to the text
+// if the title mode omits the title.
+func splitMessage(msg TelegramMessage, maxLen int, titleMode string) []TelegramMessage {
+	titleLen := composedTitleLen(msg.Title, titleMode)
+	if titleLen+utf8.RuneCountInString(msg.Text) <= maxLen {
+		return []TelegramMessage{msg}
+	}
+	if titleMode == TitleModeInline && msg.Title == "" {
+		// The marker makes the title non-empty
+		titleLen = 2
+	}
+
+	// The number of parts affects the marker length and vice versa,
+	// so repeat until the number of parts is stable.
+	var parts []string
+	partCount := 2
+	for {
+		markerLen := utf8.RuneCountInString(partMarker(partCount, partCount, msg.parseMode))
+		budget := maxLen - titleLen - markerLen
+		if budget < 1 {
+			budget = 1
+		}
+		parts = splitText(msg.Text, budget, msg.parseMode)
+		if len(parts) <= partCount {
+			break
+		}
+		partCount = len(parts)
+	}
+
+	r := make([]TelegramMessage, len(parts))
+	for i, p := range parts {
+		r[i] = msg
+		marker := partMarker(i+1, len(parts), msg.parseMode)
+		if titleMode == TitleModeNone {
+			r[i].Text = p + marker
+		} else {
+			r[i].Title = msg.Title + marker
+			r[i].Text = p
+		}
+	}
+	return r
+}
+
+// composedTitleLen returns the number of characters the title adds
+// to the text when they are combined according to the title mode,
+// see composeText.
+func composedTitleLen(title, titleMode string) int {
+	switch titleMode {
+	case TitleModeInline:
+		if title == "" {
+			return 0
+		}
+		return utf8.RuneCountInString(title) + 2
+	case TitleModeNone:
+		return 0
+	}
+	return utf8.RuneCountInString(title) + 1
+}
+
+// fitMessage splits or truncates the message exceeding MaxMessageLength
+// according to LongMessageMode. The resolved parse mode is set
+// to the message, so that the markers are escaped for it.
+func (v *validatedConfig) fitMessage(msg TelegramMessage) []TelegramMessage {
+	if msg.parseMode == "" {
+		msg.parseMode = v.ParseMode
+	}
+	if v.LongMessageMode == LongMessageModeTruncate {
+		marker := v.TruncationMarker
+		if marker == "" {
+			marker = escapeText(DefaultTruncationMarker, msg.parseMode)
+		}
+		return []TelegramMessage{truncateMessage(msg, v.MaxMessageLength, marker, v.TitleMode)}
+	}
+	return splitMessage(msg, v.MaxMessageLength, v.TitleMode)
+}
+
+// truncateMessage cuts the text of the message whose text composed
+// with the title according to the title mode exceeds maxLen characters
+// and appends the marker, so that the message fits into maxLen if possible.
+// The title that leaves no room for the marker is cut as well.
+// Never breaks a multi-byte rune or an escape sequence of the parse mode.
+func truncateMessage(msg TelegramMessage, maxLen int, marker, titleMode string) TelegramMessage {
+	titleLen := composedTitleLen(msg.Title, titleMode)
+	if titleLen+utf8.RuneCountInString(msg.Text) <= maxLen {
+		return msg
+	}
+	markerLen := utf8.RuneCountInString(marker)
+	if titleLen > 0 && titleLen > maxLen-markerLen {
+		// Leave room for the separator and the marker
+		sepLen := titleLen - utf8.RuneCountInString(msg.Title)
+		msg.Title = cutText(msg.Title, maxLen-sepLen-markerLen, msg.parseMode)
+		titleLen = composedTitleLen(msg.Title, titleMode)
+	}
+	msg.Text = cutText(msg.Text, maxLen-titleLen-markerLen, msg.parseMode) + marker
+	return msg
+}
+
+// cutText returns the beginning of the text of at most maxLen runes.
+// Never breaks a multi-byte rune or an escape sequence of the parse mode.
+func cutText(text string, maxLen int, parseMode string) string {
+	cut := 0
+	for i := 0; i < maxLen && cut < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[cut:])
+		cut += size
+	}
+	return text[:escapeSafeCut(text, cut, parseMode)]
+}
+
+func partMarker(n, m int, parseMode string) string {
+	return escapeText(fmt.Sprintf(" (part %d/%d)", n, m), parseMode)
+}
+
+// splitText splits the text into chunks of at most maxLen runes,
+// preferably at line boundaries. Line breaks at chunk boundaries are dropped.
+// Never breaks a multi-byte rune or an escape sequence of the parse mode.
+func splitText(text string, maxLen int, parseMode string) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > maxLen {
+		// Byte offset of the first rune that doesn't fit
+		cut := 0
+		for i := 0; i < maxLen; i++ {
+			_, size := utf8.DecodeRuneInString(text[cut:])
+			cut += size
+		}
+
+		if nl := strings.LastIndexByte(text[:cut+1], '\n'); nl > 0 {
+			chunks = append(chunks, text[:nl])
+			text = text[nl+1:]
+		} else {
+			cut = escapeSafeCut(text, cut, parseMode)
+			chunks = append(chunks, text[:cut])
+			text = text[cut:]
+		}
+	}
+	return append(chunks, text)
+}
@@ -0,0 +1,222 @@
+package telegram_notifier
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMessage(t *testing.T) {
+	msg := TelegramMessage{Title: "T", Text: "short"}
+	require.Equal(t, []TelegramMessage{msg}, splitMessage(msg, 100, TitleModeSeparate), "short message must not be split")
+
+	// Split at line boundaries
+	msg.Text = "line one\nline two\nline three"
+	parts := splitMessage(msg, 25, TitleModeSeparate)
+	require.Equal(t, 3, len(parts))
+	require.Equal(t, "T (part 1/3)", parts[0].Title)
+	require.Equal(t, "line one", parts[0].Text)
+	require.Equal(t, "line two", parts[1].Text)
+	require.Equal(t, "T (part 3/3)", parts[2].Title)
+	require.Equal(t, "line three", parts[2].Text)
+
+	// Long line without line breaks is split at the length limit
+	msg.Text = strings.Repeat("a", 100)
+	parts = splitMessage(msg, 40, TitleModeSeparate)
+	joined := ""
+	for _, p := range parts {
+		require.LessOrEqual(t, utf8.RuneCountInString(p.Title)+1+utf8.RuneCountInString(p.Text), 40)
+		joined += p.Text
+	}
+	require.Equal(t, msg.Text, joined, "no text must be lost")
+
+	// Multi-byte runes are never broken
+	msg.Text = strings.Repeat("я€😀", 50)
+	parts = splitMessage(msg, 25, TitleModeSeparate)
+	joined = ""
+	for _, p := range parts {
+		require.Equal(t, true, utf8.ValidString(p.Text), "part must be valid UTF-8")
+		require.LessOrEqual(t, utf8.RuneCountInString(p.Title)+1+utf8.RuneCountInString(p.Text), 25)
+		joined += p.Text
+	}
+	require.Equal(t, msg.Text, joined, "no text must be lost")
+
+	// The marker is escaped for the parse mode
+	msg = TelegramMessage{Title: "T", Text: "line one\nline two\nline three", parseMode: ParseModeMarkdownV2}
+	parts = splitMessage(msg, 26, TitleModeSeparate)
+	require.Equal(t, 3, len(parts))
+	require.Equal(t, `T \(part 1/3\)`, parts[0].Title)
+	require.Equal(t, `T \(part 3/3\)`, parts[2].Title)
+}
+
+func TestSplitMessageTitleModes(t *testing.T) {
+	composedLen := func(m TelegramMessage, titleMode string) int {
+		return utf8.RuneCountInString(composeText(m.Title, m.Text, titleMode))
+	}
+	for _, mode := range []string{TitleModeSeparate, TitleModeInline, TitleModeNone} {
+		// Exact boundary
+		exact := TelegramMessage{Title: "T", Text: strings.Repeat("a", 20-composedTitleLen("T", mode))}
+		require.Equal(t, 20, composedLen(exact, mode), "mode %q", mode)
+		require.Equal(t, []TelegramMessage{exact}, splitMessage(exact, 20, mode), "mode %q", mode)
+
+		// Over boundary
+		over := TelegramMessage{Title: "T", Text: "line one\nline two\nline three"}
+		parts := splitMessage(over, 25, mode)
+		require.Equal(t, 3, len(parts), "mode %q", mode)
+		for _, p := range parts {
+			require.LessOrEqual(t, composedLen(p, mode), 25, "mode %q", mode)
+		}
+		if mode == TitleModeNone {
+			// The marker is not lost with the title
+			require.Equal(t, "T", parts[0].Title)
+			require.Equal(t, "line one (part 1/3)", parts[0].Text)
+			require.Equal(t, "line three (part 3/3)", parts[2].Text)
+		} else {
+			require.Equal(t, "T (part 1/3)", parts[0].Title)
+			require.Equal(t, "line one", parts[0].Text)
+		}
+	}
+
+	// Inline title adds two characters
+	msg := TelegramMessage{Title: "T", Text: strings.Repeat("a", 18)}
+	require.Equal(t, []TelegramMessage{msg}, splitMessage(msg, 20, TitleModeSeparate))
+	parts := splitMessage(msg, 20, TitleModeInline)
+	require.Greater(t, len(parts), 1)
+	for _, p := range parts {
+		require.LessOrEqual(t, composedLen(p, TitleModeInline), 20)
+	}
+
+	// No title budget is reserved if the title is omitted
+	msg.Title = strings.Repeat("t", 30)
+	require.Equal(t, []TelegramMessage{msg}, splitMessage(msg, 20, TitleModeNone))
+}
+
+func TestTruncateMessageTitleModes(t *testing.T) {
+	composedLen := func(m TelegramMessage, titleMode string) int {
+		return utf8.RuneCountInString(composeText(m.Title, m.Text, titleMode))
+	}
+	for _, mode := range []string{TitleModeSeparate, TitleModeInline, TitleModeNone} {
+		exact := TelegramMessage{Title: "T", Text: strings.Repeat("a", 20-composedTitleLen("T", mode))}
+		require.Equal(t, exact, truncateMessage(exact, 20, "…", mode), "mode %q", mode)
+
+		over := TelegramMessage{Title: "T", Text: strings.Repeat("a", 30)}
+		r := truncateMessage(over, 20, "…", mode)
+		require.Equal(t, 20, composedLen(r, mode), "mode %q", mode)
+		require.Equal(t, true, strings.HasSuffix(r.Text, "…"), "mode %q", mode)
+
+		// The title too long for the marker
+		long := TelegramMessage{Title: strings.Repeat("t", 30), Text: "text"}
+		r = truncateMessage(long, 20, "…", mode)
+		require.LessOrEqual(t, composedLen(r, mode), 20, "mode %q", mode)
+	}
+
+	msg := TelegramMessage{Title: "T", Text: strings.Repeat("a", 30)}
+	require.Equal(t, "T", truncateMessage(msg, 20, "…", TitleModeInline).Title)
+	require.Equal(t, strings.Repeat("a", 16)+"…", truncateMessage(msg, 20, "…", TitleModeInline).Text)
+	require.Equal(t, strings.Repeat("a", 19)+"…", truncateMessage(msg, 20, "…", TitleModeNone).Text)
+
+	// The title is kept if it is omitted
+	msg.Title = strings.Repeat("t", 30)
+	r := truncateMessage(msg, 20, "…", TitleModeNone)
+	require.Equal(t, msg.Title, r.Title)
+	require.Equal(t, strings.Repeat("a", 19)+"…", r.Text)
+	r = truncateMessage(msg, 20, "…", TitleModeInline)
+	require.Equal(t, strings.Repeat("t", 17), r.Title)
+	require.Equal(t, "…", r.Text)
+}
+
+func TestSendSplitsLongMessages(t *testing.T) {
+	c := newTestConfig()
+	c.MaxMessageLength = 25
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, c, n)
+	tn.UnitStart()
+
+	require.Equal(t, nil, tn.SendAsync("T", "0123456789\n0123456789\n0123456789"))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 3, len(sent))
+	require.Equal(t, "T (part 1/3)", sent[0].Title)
+
+	// The configured parse mode applies to the marker
+	c.ParseMode = ParseModeMarkdownV2
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	parts := v.fitMessage(TelegramMessage{Title: "T", Text: "0123456789\n0123456789\n0123456789"})
+	require.Equal(t, `T \(part 1/3\)`, parts[0].Title)
+
+	c.MaxMessageLength = -1
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadMaxMessageLength)
+}
+
+func TestLongMessageMode(t *testing.T) {
+	msgLen := func(m TelegramMessage) int {
+		return utf8.RuneCountInString(m.Title) + 1 + utf8.RuneCountInString(m.Text)
+	}
+	c := newTestConfig()
+	c.MaxMessageLength = 20
+	for _, mode := range []string{"", LongMessageModeSplit, LongMessageModeTruncate} {
+		c.LongMessageMode = mode
+		v, err := validateConfig(c)
+		require.Equal(t, nil, err)
+
+		// Exact boundary: "T\n" and 18 characters
+		exact := TelegramMessage{Title: "T", Text: strings.Repeat("a", 18)}
+		require.Equal(t, []TelegramMessage{exact}, v.fitMessage(exact), "mode %q", mode)
+
+		// Over boundary
+		over := TelegramMessage{Title: "T", Text: strings.Repeat("a", 19)}
+		parts := v.fitMessage(over)
+		if mode == LongMessageModeTruncate {
+			require.Equal(t, 1, len(parts))
+			require.Equal(t, "T", parts[0].Title)
+			require.Equal(t, "aaaaa"+DefaultTruncationMarker, parts[0].Text)
+			require.Equal(t, 20, msgLen(parts[0]))
+		} else {
+			require.Greater(t, len(parts), 1, "mode %q", mode)
+		}
+
+		// Multi-byte runes are never broken
+		multibyte := TelegramMessage{Title: "T", Text: strings.Repeat("я€😀", 10)}
+		for _, p := range v.fitMessage(multibyte) {
+			require.Equal(t, true, utf8.ValidString(p.Text), "mode %q", mode)
+			require.LessOrEqual(t, msgLen(p), 20, "mode %q", mode)
+		}
+	}
+
+	c.LongMessageMode = LongMessageModeTruncate
+	c.TruncationMarker = "…"
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	parts := v.fitMessage(TelegramMessage{Title: "T", Text: strings.Repeat("я€😀", 10)})
+	require.Equal(t, []TelegramMessage{{Title: "T", Text: "я€😀я€😀я€😀я€😀я€😀я€…"}}, parts)
+
+	// MarkdownV2 escape sequences are not broken
+	parts = v.fitMessage(TelegramMessage{Title: "T", Text: strings.Repeat("a", 16) + `\.\.\.`, parseMode: ParseModeMarkdownV2})
+	require.Equal(t, strings.Repeat("a", 16)+"…", parts[0].Text)
+
+	// The default marker is escaped for the parse mode
+	c.TruncationMarker = ""
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	parts = v.fitMessage(TelegramMessage{Title: "T", Text: strings.Repeat("a", 30), parseMode: ParseModeMarkdownV2})
+	require.Equal(t, "aaa"+` …\(truncated\)`, parts[0].Text)
+	require.Equal(t, 20, msgLen(parts[0]))
+
+	// The title too long for the marker is cut
+	parts = v.fitMessage(TelegramMessage{Title: strings.Repeat("t", 30), Text: "text"})
+	require.Equal(t, "tttttt", parts[0].Title)
+	require.Equal(t, DefaultTruncationMarker, parts[0].Text)
+	require.Equal(t, 20, msgLen(parts[0]))
+	parts = v.fitMessage(TelegramMessage{Title: strings.Repeat(`\.`, 10), Text: "text", parseMode: ParseModeMarkdownV2})
+	require.Equal(t, `\.\.`, parts[0].Title)
+	require.LessOrEqual(t, msgLen(parts[0]), 20)
+
+	c.LongMessageMode = "cut"
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLongMessageMode)
+}
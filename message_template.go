@@ -0,0 +1,75 @@
+package telegram_notifier
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// MessageTemplateData is the data the message template is executed with,
+// see Config.MessageTemplate.
+type MessageTemplateData struct {
+	// Level is the log level name, e.g. "error".
+	Level string
+
+	// Title is the title of the forwarded message, e.g. "ERROR".
+	Title string
+
+	// Message is the log message.
+	Message string
+
+	// Time is the time the message is forwarded at in the time zone
+	// defined by LogTimeZone and LogUseUTC.
+	Time time.Time
+
+	// Hostname is the host name resolved when the config is validated.
+	Hostname string
+
+	// PID is the process ID.
+	PID int
+
+	// Caller is the log call site, e.g. "main.go:42", if the level
+	// is listed in IncludeCallerForLevels, otherwise empty.
+	Caller string
+}
+
+// parseMessageTemplate compiles the message template,
+// nil means the default format.
+func parseMessageTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	t, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadMessageTemplate, err)
+	}
+	return t, nil
+}
+
+// renderLogMessage formats the forwarded log message with the message
+// template. If the template fails to execute, the default format is used.
+func (u *TelegramNotifier) renderLogMessage(cfg *validatedConfig, level zerolog.Level, title, message, caller string) string {
+	now := u.clock.Now()
+	if cfg.MessageTemplate == nil {
+		return cfg.addLogMetadata(message, caller, now)
+	}
+	var b strings.Builder
+	err := cfg.MessageTemplate.Execute(&b, MessageTemplateData{
+		Level:    level.String(),
+		Title:    title,
+		Message:  message,
+		Time:     now.In(cfg.LogLocation),
+		Hostname: cfg.Hostname,
+		PID:      os.Getpid(),
+		Caller:   caller,
+	})
+	if err != nil {
+		u.internalLog().Error().Err(err).Msg("failed to execute message template, default format used")
+		return cfg.addLogMetadata(message, caller, now)
+	}
+	return b.String()
+}
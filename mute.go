@@ -0,0 +1,63 @@
+package telegram_notifier
+
+import (
+	"errors"
+	"sync"
+)
+
+// errAllChatsMuted is returned by send if all the receivers
+// of the message are muted. Such messages are not sent,
+// they are counted as suppressed.
+var errAllChatsMuted = errors.New("all chats muted")
+
+// mutedChats is the set of the chats muted with MuteChat.
+type mutedChats struct {
+	mu  sync.RWMutex
+	ids map[int64]struct{}
+}
+
+// MuteChat temporarily stops sending messages to the chat,
+// e.g. during the maintenance window of the team, it is thread-safe.
+// The chat remains in ChatIds and receives messages again after UnmuteChat.
+// Muting applies to all messages including the ones sent to the explicitly
+// specified chats. The messages whose chats are all muted are not sent,
+// they are counted in Stats.Suppressed. The chats remain muted when the unit is paused
+// and restarted, and are unmuted when the unit quits.
+func (u *TelegramNotifier) MuteChat(id int64) {
+	u.mutedChats.mu.Lock()
+	defer u.mutedChats.mu.Unlock()
+	if u.mutedChats.ids == nil {
+		u.mutedChats.ids = make(map[int64]struct{})
+	}
+	u.mutedChats.ids[id] = struct{}{}
+}
+
+// UnmuteChat resumes sending messages to the chat muted with MuteChat,
+// it is thread-safe.
+func (u *TelegramNotifier) UnmuteChat(id int64) {
+	u.mutedChats.mu.Lock()
+	defer u.mutedChats.mu.Unlock()
+	delete(u.mutedChats.ids, id)
+}
+
+// unmuted returns the chats that are not muted.
+func (m *mutedChats) unmuted(chatIds []int64) []int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.ids) == 0 {
+		return chatIds
+	}
+	r := make([]int64, 0, len(chatIds))
+	for _, id := range chatIds {
+		if _, ok := m.ids[id]; !ok {
+			r = append(r, id)
+		}
+	}
+	return r
+}
+
+func (m *mutedChats) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids = nil
+}
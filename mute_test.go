@@ -0,0 +1,100 @@
+package telegram_notifier
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuteChat(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2, 3}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	tn.MuteChat(2)
+	require.Equal(t, nil, tn.Send(context.Background(), "1", "text"))
+	require.Equal(t, nil, tn.SendToChats([]int64{2}, "2", "text"))
+
+	// The chats remain muted when the unit is paused and restarted
+	r = tn.UnitPause()
+	require.Equal(t, true, r.OK)
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("3", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+
+	tn.UnmuteChat(2)
+	require.Equal(t, nil, tn.Send(context.Background(), "4", "text"))
+
+	// The chats are unmuted when the unit quits
+	tn.MuteChat(1)
+	tn.UnitQuit()
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "5", "text"))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, []string{"1", "3", "4", "5"}, sentTitles(n), "message 2 must not be sent to the muted chat")
+	require.Equal(t, []int64{1, 3}, sent[0].chatIds)
+	require.Equal(t, []int64{1, 3}, sent[1].chatIds)
+	require.Equal(t, []int64{1, 2, 3}, sent[2].chatIds)
+	require.Equal(t, []int64{1, 2, 3}, sent[3].chatIds)
+	require.Equal(t, []int64{1, 2, 3}, c.ChatIds, "muted chats must remain in the config")
+}
+
+func TestMuteChatBotAPI(t *testing.T) {
+	s := newFakeBotAPIServer(t, nil)
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	tn := newBotAPITestNotifier(t, s, c)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	tn.MuteChat(1)
+	_, err := tn.SendAndGetIDs(context.Background(), "title", "text")
+	require.Equal(t, nil, err)
+	requests := s.Requests()
+	require.Equal(t, 2, len(requests))
+	require.Equal(t, float64(2), requests[1].Params["chat_id"])
+
+	tn.UnitQuit()
+}
+
+func TestMuteAllChats(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	tn := newTestNotifier(t, c, n)
+
+	var succeeded atomic.Int32
+	tn.SetOnSendSuccess(func(msg TelegramMessage) {
+		succeeded.Add(1)
+	})
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The messages to the muted chats only are suppressed, not sent
+	tn.MuteChat(1)
+	tn.MuteChat(2)
+	require.Equal(t, nil, tn.Send(context.Background(), "sync", "text"))
+	require.Equal(t, nil, tn.SendAsync("async", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	require.Equal(t, 0, n.Calls())
+	s := tn.Stats()
+	require.Equal(t, uint64(0), s.Sent)
+	require.Equal(t, uint64(0), s.Failed)
+	require.Equal(t, uint64(2), s.Suppressed)
+	require.Equal(t, int32(0), succeeded.Load())
+}
@@ -0,0 +1,66 @@
+package telegram_notifier
+
+import (
+	"math"
+	"time"
+)
+
+// Option configures a TelegramNotifier created with NewWithOptions.
+type Option func(c *Config)
+
+// WithBotToken sets the Telegram bot secret token.
+func WithBotToken(token string) Option {
+	return func(c *Config) {
+		c.BotToken = token
+	}
+}
+
+// WithChatIds sets the receivers of notifications.
+func WithChatIds(chatIds ...int64) Option {
+	return func(c *Config) {
+		c.ChatIds = append([]int64(nil), chatIds...)
+	}
+}
+
+// WithLogLevels sets the log levels the messages must have
+// to be sent to Telegram when integrated with `igulib/app_logger`.
+func WithLogLevels(levels ...string) Option {
+	return func(c *Config) {
+		c.LogLevels = append([]string(nil), levels...)
+	}
+}
+
+// WithSendTimeout sets the timeout to send a message.
+// The timeout is rounded up to whole seconds.
+func WithSendTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.SendTimeoutSec = int(math.Ceil(d.Seconds()))
+	}
+}
+
+// WithParseMode sets how Telegram formats the messages:
+// "" (plain text), ParseModeMarkdownV2 or ParseModeHTML.
+func WithParseMode(parseMode string) Option {
+	return func(c *Config) {
+		c.ParseMode = parseMode
+	}
+}
+
+// WithFallbackServices sets the services used to deliver the messages
+// that failed to be sent via Telegram, see Config.FallbackServices.
+func WithFallbackServices(services ...MessageSender) Option {
+	return func(c *Config) {
+		c.FallbackServices = append([]MessageSender(nil), services...)
+	}
+}
+
+// NewWithOptions creates a new TelegramNotifier unit configured
+// with the specified options instead of a Config.
+// The resulting config is validated the same way as in New.
+func NewWithOptions(unitName string, opts ...Option) (*TelegramNotifier, error) {
+	c := &Config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return New(unitName, c)
+}
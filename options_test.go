@@ -0,0 +1,41 @@
+package telegram_notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	token := newTestConfig().BotToken
+
+	tn, err := NewWithOptions(t.Name(),
+		WithBotToken(token),
+		WithChatIds(1, -1002),
+		WithLogLevels("error", "warning"),
+		WithSendTimeout(1500*time.Millisecond),
+		WithParseMode(ParseModeHTML),
+	)
+	require.Equal(t, nil, err, "telegram_notifier must be created successfully")
+
+	v := tn.cfg()
+	require.Equal(t, token, v.BotToken)
+	require.Equal(t, []int64{1, -1002}, v.ChatIds)
+	require.Equal(t, []zerolog.Level{zerolog.ErrorLevel, zerolog.WarnLevel}, v.LogLevels)
+	require.Equal(t, 2*time.Second, v.SendTimeout, "send timeout must be rounded up to seconds")
+	require.Equal(t, ParseModeHTML, v.ParseMode)
+
+	// Unset options fall back to the defaults
+	require.Equal(t, DefaultSendConcurrency, v.SendConcurrency)
+	require.Equal(t, DefaultMaxMessageLength, v.MaxMessageLength)
+
+	// Options are validated
+	_, err = NewWithOptions(t.Name()+"_bad",
+		WithBotToken(token),
+		WithParseMode("Markdown"),
+	)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.ErrorIs(t, err, ErrBadParseMode)
+}
@@ -0,0 +1,76 @@
+// Package otel_tracing creates OpenTelemetry spans for the messages sent
+// by a TelegramNotifier. It is a separate package so that
+// the telegram_notifier package doesn't depend on OpenTelemetry.
+package otel_tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/igulib/telegram_notifier"
+)
+
+// SpanName is the name of the span created for every sent message.
+const SpanName = "telegram.send"
+
+// instrumentationName identifies the tracer.
+const instrumentationName = "github.com/igulib/telegram_notifier"
+
+// Span attribute keys.
+const (
+	AttrChatCount     = attribute.Key("telegram.chat_count")
+	AttrMessageLength = attribute.Key("telegram.message_length")
+	AttrAsync         = attribute.Key("telegram.async")
+	AttrSuccess       = attribute.Key("telegram.success")
+)
+
+// Target is implemented by *telegram_notifier.TelegramNotifier.
+type Target interface {
+	SetTracer(t telegram_notifier.Tracer)
+}
+
+// SetTracerProvider makes the TelegramNotifier unit create a span
+// using the tracer provider for every message it sends.
+func SetTracerProvider(t Target, tp trace.TracerProvider) {
+	t.SetTracer(New(tp))
+}
+
+// Tracer implements telegram_notifier.Tracer using OpenTelemetry.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates a Tracer using the tracer provider.
+func New(tp trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// StartSendSpan implements telegram_notifier.Tracer.
+func (t *Tracer) StartSendSpan(ctx context.Context, info telegram_notifier.SendSpanInfo) (context.Context, telegram_notifier.SendSpan) {
+	ctx, span := t.tracer.Start(ctx, SpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			AttrChatCount.Int(info.ChatCount),
+			AttrMessageLength.Int(info.MessageLength),
+			AttrAsync.Bool(info.Async),
+		),
+	)
+	return ctx, sendSpan{span: span}
+}
+
+// sendSpan implements telegram_notifier.SendSpan.
+type sendSpan struct {
+	span trace.Span
+}
+
+func (s sendSpan) End(err error) {
+	s.span.SetAttributes(AttrSuccess.Bool(err == nil))
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,118 @@
+package otel_tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/igulib/telegram_notifier"
+)
+
+// fakeSender fails the messages with the "fail" title.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (s *fakeSender) Send(ctx context.Context, subject, message string) error {
+	if subject == "fail" {
+		return errors.New("Bad Request: chat not found")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+	return nil
+}
+
+func spanAttrs(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	r := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		r[kv.Key] = kv.Value
+	}
+	return r
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tn, err := telegram_notifier.New(t.Name(), &telegram_notifier.Config{
+		BotToken:          "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:           []int64{1, 2},
+		MaxMessagesPerSec: -1,
+		MaxRetries:        -1,
+	})
+	require.Equal(t, nil, err)
+	tn.SetSender(&fakeSender{})
+	SetTracerProvider(tn, tp)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// Asynchronous message span is a child of the caller's span
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	require.Equal(t, nil, tn.SendAsyncCtx(parentCtx, "title", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	parent.End()
+
+	require.Equal(t, nil, tn.Send(context.Background(), "sync", "text"))
+	require.NotEqual(t, nil, tn.Send(context.Background(), "fail", "text"))
+	tn.UnitQuit()
+
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == SpanName {
+			spans = append(spans, s)
+		}
+	}
+	require.Equal(t, 3, len(spans))
+
+	async := spans[0]
+	require.Equal(t, parent.SpanContext().SpanID(), async.Parent().SpanID())
+	require.Equal(t, map[attribute.Key]attribute.Value{
+		AttrChatCount:     attribute.IntValue(2),
+		AttrMessageLength: attribute.IntValue(len("title\ntext")),
+		AttrAsync:         attribute.BoolValue(true),
+		AttrSuccess:       attribute.BoolValue(true),
+	}, spanAttrs(async))
+	require.Equal(t, codes.Unset, async.Status().Code)
+
+	require.Equal(t, false, spans[1].Parent().IsValid())
+	require.Equal(t, attribute.BoolValue(false), spanAttrs(spans[1])[AttrAsync])
+	require.Equal(t, attribute.BoolValue(true), spanAttrs(spans[1])[AttrSuccess])
+
+	failed := spans[2]
+	require.Equal(t, attribute.BoolValue(false), spanAttrs(failed)[AttrSuccess])
+	require.Equal(t, codes.Error, failed.Status().Code)
+	require.Equal(t, 1, len(failed.Events()))
+	require.Equal(t, "exception", failed.Events()[0].Name)
+}
+
+func TestTracerDisabled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tn, err := telegram_notifier.New(t.Name(), &telegram_notifier.Config{
+		BotToken:          "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:           []int64{1},
+		MaxMessagesPerSec: -1,
+	})
+	require.Equal(t, nil, err)
+	tn.SetSender(&fakeSender{})
+	SetTracerProvider(tn, tp)
+	tn.SetTracer(nil)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	tn.UnitQuit()
+
+	require.Equal(t, 0, len(recorder.Ended()))
+}
@@ -0,0 +1,57 @@
+package telegram_notifier
+
+import (
+	"context"
+	"time"
+)
+
+// pingCacheTTL is how long the result of Ping is reused.
+var pingCacheTTL = 5 * time.Second
+
+// pingResult is the cached result of Ping.
+type pingResult struct {
+	err      error
+	at       time.Time
+	botToken string
+}
+
+// Ping checks that Telegram Bot API is reachable with the configured
+// bot token, proxy and API server, it is thread-safe. Returns nil on success.
+// Unlike UnitAvailability, which only reflects the unit lifecycle state,
+// it calls the getMe method, so it is suitable for readiness probes.
+// The unit doesn't need to be started and no chats are accessed.
+// The result is cached for a few seconds to avoid flooding Telegram
+// with frequent probes. In dry run mode Telegram is not accessed
+// and nil is returned.
+func (u *TelegramNotifier) Ping(ctx context.Context) error {
+	cfg := u.cfg()
+	if cfg.DryRun {
+		return nil
+	}
+
+	u.pingLock.Lock()
+	cached := u.lastPing
+	u.pingLock.Unlock()
+	if cached.botToken == cfg.BotToken && !cached.at.IsZero() && u.clock.Now().Sub(cached.at) < pingCacheTTL {
+		return cached.err
+	}
+
+	api := newConfiguredBotAPI(cfg)
+	if notifier, ok := u.currentNotifier().(botAPIProvider); ok {
+		// Reuse the connections of the running service
+		api = notifier.apiClient()
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, cfg.SendTimeout)
+	defer cancel()
+	err := api.getMe(callCtx)
+	// The caller's cancellation doesn't tell whether Telegram is reachable
+	if ctx.Err() != nil {
+		return err
+	}
+
+	u.pingLock.Lock()
+	u.lastPing = pingResult{err: err, at: u.clock.Now(), botToken: cfg.BotToken}
+	u.pingLock.Unlock()
+	return err
+}
@@ -0,0 +1,60 @@
+package telegram_notifier
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	s := newFakeBotAPIServer(t, nil)
+	c := newTestConfig()
+	tn := newBotAPITestNotifier(t, s, c)
+
+	getMeCalls := func() int {
+		n := 0
+		for _, r := range s.Requests() {
+			if strings.HasSuffix(r.Path, "/getMe") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The unit doesn't need to be started, the result is cached
+	require.Equal(t, nil, tn.Ping(context.Background()))
+	require.Equal(t, nil, tn.Ping(context.Background()))
+	require.Equal(t, 1, getMeCalls())
+
+	s.SetHandler(func(r botAPIRequest) (int, string) {
+		return http.StatusUnauthorized, `{"ok":false,"error_code":401,"description":"Unauthorized"}`
+	})
+	require.Equal(t, nil, tn.Ping(context.Background()))
+
+	defer func(ttl time.Duration) { pingCacheTTL = ttl }(pingCacheTTL)
+	pingCacheTTL = 0
+	err := tn.Ping(context.Background())
+	var apiErr *apiError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Code)
+	require.Equal(t, 2, getMeCalls())
+
+	// Cancelled probes are not cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, tn.Ping(ctx), context.Canceled)
+	require.Equal(t, http.StatusUnauthorized, tn.lastPing.err.(*apiError).Code)
+}
+
+func TestPingDryRun(t *testing.T) {
+	c := newTestConfig()
+	c.DryRun = true
+	c.ApiBaseURL = "http://127.0.0.1:1"
+	tn, err := New(t.Name(), c)
+	require.Equal(t, nil, err)
+	require.Equal(t, nil, tn.Ping(context.Background()))
+}
@@ -0,0 +1,83 @@
+// Package prometheus_collector exposes TelegramNotifier metrics to Prometheus.
+// It is a separate package so that the telegram_notifier package
+// doesn't depend on the Prometheus client.
+package prometheus_collector
+
+import (
+	"time"
+
+	"github.com/igulib/app"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/igulib/telegram_notifier"
+)
+
+// Source is implemented by *telegram_notifier.TelegramNotifier.
+type Source interface {
+	UnitRunner() *app.UnitLifecycleRunner
+	Stats() telegram_notifier.Stats
+	AddSendDurationObserver(f func(d time.Duration))
+}
+
+// Collector implements prometheus.Collector for a TelegramNotifier unit.
+// All metrics are labeled with the unit name.
+type Collector struct {
+	source Source
+
+	sent    *prometheus.Desc
+	failed  *prometheus.Desc
+	dropped *prometheus.Desc
+
+	sendDuration prometheus.Histogram
+}
+
+// New creates a Collector for the specified TelegramNotifier unit.
+// Only one Collector should be created per unit.
+func New(s Source) *Collector {
+	labels := prometheus.Labels{"unit": s.UnitRunner().Name()}
+	c := &Collector{
+		source: s,
+		sent: prometheus.NewDesc(
+			"telegram_notifier_messages_sent_total",
+			"Number of messages successfully sent via Telegram.",
+			nil, labels,
+		),
+		failed: prometheus.NewDesc(
+			"telegram_notifier_messages_failed_total",
+			"Number of messages that failed to be sent via Telegram.",
+			nil, labels,
+		),
+		dropped: prometheus.NewDesc(
+			"telegram_notifier_messages_dropped_total",
+			"Number of messages dropped because the message buffer was full.",
+			nil, labels,
+		),
+		sendDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "telegram_notifier_send_duration_seconds",
+			Help:        "Duration of attempts to send a message via Telegram.",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+	}
+	s.AddSendDurationObserver(func(d time.Duration) {
+		c.sendDuration.Observe(d.Seconds())
+	})
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.sent
+	ch <- c.failed
+	ch <- c.dropped
+	c.sendDuration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.source.Stats()
+	ch <- prometheus.MustNewConstMetric(c.sent, prometheus.CounterValue, float64(s.Sent))
+	ch <- prometheus.MustNewConstMetric(c.failed, prometheus.CounterValue, float64(s.Failed))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(s.Dropped))
+	c.sendDuration.Collect(ch)
+}
@@ -0,0 +1,100 @@
+package prometheus_collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igulib/app"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/igulib/telegram_notifier"
+)
+
+// fakeSource simulates the sends of a TelegramNotifier unit.
+type fakeSource struct {
+	runner    *app.UnitLifecycleRunner
+	stats     telegram_notifier.Stats
+	observers []func(d time.Duration)
+}
+
+func (s *fakeSource) UnitRunner() *app.UnitLifecycleRunner {
+	return s.runner
+}
+
+func (s *fakeSource) Stats() telegram_notifier.Stats {
+	return s.stats
+}
+
+func (s *fakeSource) AddSendDurationObserver(f func(d time.Duration)) {
+	s.observers = append(s.observers, f)
+}
+
+func (s *fakeSource) send(d time.Duration, ok bool) {
+	for _, f := range s.observers {
+		f(d)
+	}
+	if ok {
+		s.stats.Sent++
+	} else {
+		s.stats.Failed++
+	}
+}
+
+func TestCollector(t *testing.T) {
+	s := &fakeSource{runner: app.NewUnitLifecycleRunner("notifier")}
+	c := New(s)
+
+	for i := 0; i < 5; i++ {
+		s.send(20*time.Millisecond, true)
+	}
+	s.send(2*time.Second, false)
+	s.stats.Dropped = 3
+
+	expected := `
+# HELP telegram_notifier_messages_sent_total Number of messages successfully sent via Telegram.
+# TYPE telegram_notifier_messages_sent_total counter
+telegram_notifier_messages_sent_total{unit="notifier"} 5
+# HELP telegram_notifier_messages_failed_total Number of messages that failed to be sent via Telegram.
+# TYPE telegram_notifier_messages_failed_total counter
+telegram_notifier_messages_failed_total{unit="notifier"} 1
+# HELP telegram_notifier_messages_dropped_total Number of messages dropped because the message buffer was full.
+# TYPE telegram_notifier_messages_dropped_total counter
+telegram_notifier_messages_dropped_total{unit="notifier"} 3
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"telegram_notifier_messages_sent_total",
+		"telegram_notifier_messages_failed_total",
+		"telegram_notifier_messages_dropped_total",
+	)
+	require.Equal(t, nil, err)
+
+	require.Equal(t, 4, testutil.CollectAndCount(c), "3 counters and 1 histogram expected")
+	require.Equal(t, nil, testutil.CollectAndCompare(c.sendDuration, strings.NewReader(`
+# HELP telegram_notifier_send_duration_seconds Duration of attempts to send a message via Telegram.
+# TYPE telegram_notifier_send_duration_seconds histogram
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.005"} 0
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.01"} 0
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.025"} 5
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.05"} 5
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.1"} 5
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.25"} 5
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="0.5"} 5
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="1"} 5
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="2.5"} 6
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="5"} 6
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="10"} 6
+telegram_notifier_send_duration_seconds_bucket{unit="notifier",le="+Inf"} 6
+telegram_notifier_send_duration_seconds_sum{unit="notifier"} 2.1
+telegram_notifier_send_duration_seconds_count{unit="notifier"} 6
+`)))
+
+	// TelegramNotifier implements Source
+	tn, err := telegram_notifier.New("TestCollector", &telegram_notifier.Config{
+		BotToken: "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:  []int64{1},
+	})
+	require.Equal(t, nil, err)
+	require.Equal(t, 4, testutil.CollectAndCount(New(tn)))
+}
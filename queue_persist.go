@@ -0,0 +1,287 @@
+package telegram_notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+)
+
+// maxPersistRecordSize limits the size of a persisted message record,
+// larger length prefixes are considered corrupted.
+const maxPersistRecordSize = 16 << 20
+
+// persistRecord is a record of the persisted queue file.
+// The file is a sequence of records, each prefixed with its length
+// as a 4-byte big-endian integer. A message is added by a record
+// with its fields and removed by a record with the same ID and Done set.
+type persistRecord struct {
+	Id   uint64 `json:"id"`
+	Done bool   `json:"done,omitempty"`
+
+	Title                 string  `json:"title,omitempty"`
+	Text                  string  `json:"text,omitempty"`
+	ChatIds               []int64 `json:"chat_ids,omitempty"`
+	ThreadId              int     `json:"thread_id,omitempty"`
+	ParseMode             string  `json:"parse_mode,omitempty"`
+	Silent                bool    `json:"silent,omitempty"`
+	DisableWebPagePreview *bool   `json:"disable_web_page_preview,omitempty"`
+	ProtectContent        *bool   `json:"protect_content,omitempty"`
+
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+func newPersistRecord(id uint64, msg TelegramMessage) persistRecord {
+	return persistRecord{
+		Id:                    id,
+		Title:                 msg.Title,
+		Text:                  msg.Text,
+		ChatIds:               msg.chatIds,
+		ThreadId:              msg.threadId,
+		ParseMode:             msg.parseMode,
+		Silent:                msg.silent,
+		DisableWebPagePreview: msg.disableWebPagePreview,
+		ProtectContent:        msg.protectContent,
+		Retry:                 msg.retry,
+	}
+}
+
+func (r *persistRecord) message() TelegramMessage {
+	return TelegramMessage{
+		Title:                 r.Title,
+		Text:                  r.Text,
+		ctx:                   context.Background(),
+		chatIds:               r.ChatIds,
+		threadId:              r.ThreadId,
+		parseMode:             r.ParseMode,
+		silent:                r.Silent,
+		disableWebPagePreview: r.DisableWebPagePreview,
+		protectContent:        r.ProtectContent,
+		persistIds:            []uint64{r.Id},
+		retry:                 r.Retry,
+	}
+}
+
+func encodePersistRecord(buf *bytes.Buffer, r persistRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	buf.Write(size[:])
+	buf.Write(data)
+	return nil
+}
+
+// decodePersistRecords returns the messages added and not removed,
+// in the order they were added. The records that fail to decode
+// are skipped, a corrupted length prefix ends the file.
+func decodePersistRecords(data []byte) (pending []persistRecord, corrupted int) {
+	added := make(map[uint64]persistRecord)
+	for len(data) > 0 {
+		if len(data) < 4 {
+			corrupted++
+			break
+		}
+		size := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if size > maxPersistRecordSize || int(size) > len(data) {
+			corrupted++
+			break
+		}
+		var r persistRecord
+		if err := json.Unmarshal(data[:size], &r); err != nil || r.Id == 0 {
+			corrupted++
+		} else if r.Done {
+			delete(added, r.Id)
+		} else {
+			added[r.Id] = r
+		}
+		data = data[size:]
+	}
+
+	for _, r := range added {
+		pending = append(pending, r)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Id < pending[j].Id })
+	return pending, corrupted
+}
+
+// queueStore persists the enqueued messages until they are processed,
+// see Config.QueuePersistPath.
+type queueStore struct {
+	mu      sync.Mutex
+	f       *os.File
+	nextId  uint64
+	pending int
+}
+
+// openQueueStore loads the messages left in the file by the previous run,
+// compacts the file and opens it to persist new messages.
+// Returns the number of corrupted records skipped.
+func openQueueStore(path string) (*queueStore, []persistRecord, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, 0, err
+	}
+	pending, corrupted := decodePersistRecords(data)
+
+	// The file is replaced atomically to keep the messages
+	// if the process crashes while compacting
+	var buf bytes.Buffer
+	for _, r := range pending {
+		if err := encodePersistRecord(&buf, r); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0o600); err != nil {
+		return nil, nil, 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, nil, 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	s := &queueStore{f: f, nextId: 1, pending: len(pending)}
+	if len(pending) > 0 {
+		s.nextId = pending[len(pending)-1].Id + 1
+	}
+	return s, pending, corrupted, nil
+}
+
+// add persists the message and returns its ID.
+func (s *queueStore) add(msg TelegramMessage) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextId
+	var buf bytes.Buffer
+	if err := encodePersistRecord(&buf, newPersistRecord(id, msg)); err != nil {
+		return 0, err
+	}
+	if _, err := s.f.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	s.nextId++
+	s.pending++
+	return id, nil
+}
+
+// remove removes the processed messages. The file is truncated
+// when no messages are left to keep it small.
+func (s *queueStore) remove(ids []uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending -= len(ids)
+	if s.pending <= 0 {
+		s.pending = 0
+		return s.f.Truncate(0)
+	}
+	var buf bytes.Buffer
+	for _, id := range ids {
+		if err := encodePersistRecord(&buf, persistRecord{Id: id, Done: true}); err != nil {
+			return err
+		}
+	}
+	_, err := s.f.Write(buf.Bytes())
+	return err
+}
+
+func (s *queueStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// openQueue opens the persisted queue if configured and re-enqueues
+// the messages left by the previous run. Failures are logged
+// and the unit runs without persistence.
+// This method should only be called from UnitStart.
+func (u *TelegramNotifier) openQueue() {
+	path := u.cfg().QueuePersistPath
+	if path == "" {
+		return
+	}
+	s, pending, corrupted, err := openQueueStore(path)
+	if err != nil {
+		u.internalLog().Error().Err(err).Str("path", path).
+			Msg("failed to open persisted message queue, messages are not persisted")
+		return
+	}
+	if corrupted > 0 {
+		u.internalLog().Warn().Int("records", corrupted).Str("path", path).
+			Msg("corrupted records of persisted message queue skipped")
+	}
+	u.queueStore.Store(s)
+
+	if len(pending) == 0 {
+		return
+	}
+	u.internalLog().Info().Int("messages", len(pending)).
+		Msg("resending messages left in persisted message queue")
+	// UnitQuit waits for the messages, but UnitStart must not
+	// be blocked while the buffer is full.
+	for range pending {
+		u.addRequest()
+	}
+	tgServiceDone := u.tgServiceDone
+	go func() {
+		for _, r := range pending {
+			_ = u.enqueueRequest(r.message(), tgServiceDone)
+		}
+	}()
+}
+
+// closeQueue closes the persisted queue, the messages
+// that were not processed remain in the file.
+func (u *TelegramNotifier) closeQueue() {
+	if s := u.queueStore.Swap(nil); s != nil {
+		if err := s.close(); err != nil {
+			u.internalLog().Error().Err(err).Msg("failed to close persisted message queue")
+		}
+	}
+}
+
+// persistMessage persists the message being enqueued
+// if it is not persisted yet and returns it with the persisted ID.
+func (u *TelegramNotifier) persistMessage(msg TelegramMessage) TelegramMessage {
+	s := u.queueStore.Load()
+	if s == nil || len(msg.persistIds) > 0 {
+		return msg
+	}
+	id, err := s.add(msg)
+	if err != nil {
+		u.internalLog().Error().Err(err).Msg("failed to persist message")
+		return msg
+	}
+	msg.persistIds = []uint64{id}
+	return msg
+}
+
+// unpersistMessage removes the processed message from the persisted queue.
+func (u *TelegramNotifier) unpersistMessage(msg TelegramMessage) {
+	s := u.queueStore.Load()
+	if s == nil || len(msg.persistIds) == 0 {
+		return
+	}
+	if err := s.remove(msg.persistIds); err != nil {
+		u.internalLog().Error().Err(err).Msg("failed to remove message from persisted queue")
+	}
+}
+
+// aborted returns true if UnitQuit timed out, or if the message
+// failed with err because UnitQuit cancelled its send.
+func (u *TelegramNotifier) aborted(msg TelegramMessage, err error) bool {
+	if u.tgServiceCtx.Err() != nil {
+		return true
+	}
+	return u.tgInFlightCtx.Err() != nil && msg.ctx.Err() == nil && errors.Is(err, context.Canceled)
+}
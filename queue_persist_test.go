@@ -0,0 +1,140 @@
+package telegram_notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueuePersistRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+	c := newTestConfig()
+	c.QueuePersistPath = path
+	c.ShutdownTimeoutSec = 1
+	c.SendConcurrency = 1
+
+	// The messages are not sent before UnitQuit times out
+	stuck := &fakeNotifier{delay: time.Hour}
+	tn := newTestNotifier(t, c, stuck)
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	for _, title := range []string{"first", "second", "third"} {
+		require.Equal(t, nil, tn.SendAsync(title, "text"))
+	}
+	r = tn.UnitQuit()
+	require.ErrorIs(t, r.CollateralError, ErrShutdownTimeout)
+	require.Equal(t, 0, len(stuck.Sent()))
+
+	// The messages are sent after restart
+	n := &fakeNotifier{}
+	tn = newTestNotifier(t, c, n)
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("fourth", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.ElementsMatch(t, []string{"first", "second", "third", "fourth"}, sentTitles(n))
+	tn.UnitQuit()
+
+	// Nothing is left after the messages are sent
+	data, err := os.ReadFile(path)
+	require.Equal(t, nil, err)
+	require.Equal(t, 0, len(data))
+
+	n = &fakeNotifier{}
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+	require.Equal(t, 0, len(n.Sent()))
+}
+
+func TestQueuePersistQuitCancelsSends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+	c := newTestConfig()
+	c.QueuePersistPath = path
+	c.SendTimeoutSec = 60
+	c.SendConcurrency = 1
+
+	// The ongoing send is cancelled by UnitQuit
+	s := &ctxRecordingSender{started: make(chan struct{}, 1), errs: make(chan error, 1)}
+	tn := newTestNotifier(t, c, s)
+	tn.SetInternalLogger(zerolog.Nop())
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("first", "text"))
+	<-s.started
+	r = tn.UnitQuit()
+	require.Equal(t, nil, r.CollateralError)
+
+	// The message is sent after restart
+	n := &fakeNotifier{}
+	tn = newTestNotifier(t, c, n)
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"first"}, sentTitles(n))
+	tn.UnitQuit()
+}
+
+func TestQueuePersistCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+
+	// Simulate a crash: the first message was sent, the others were not
+	var buf bytes.Buffer
+	silent := true
+	require.Equal(t, nil, encodePersistRecord(&buf, persistRecord{Id: 1, Title: "sent", Text: "text"}))
+	require.Equal(t, nil, encodePersistRecord(&buf, persistRecord{
+		Id: 2, Title: "pending", Text: "text", ChatIds: []int64{5}, ThreadId: 7, Silent: silent,
+		ProtectContent: &silent,
+	}))
+	require.Equal(t, nil, encodePersistRecord(&buf, persistRecord{Id: 1, Done: true}))
+	// Corrupted record is skipped
+	buf.Write([]byte{0, 0, 0, 5})
+	buf.WriteString("{bad}")
+	require.Equal(t, nil, encodePersistRecord(&buf, persistRecord{Id: 3, Title: "last", Text: "text"}))
+	// Truncated record at the end
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], 100)
+	buf.Write(size[:])
+	buf.WriteString(`{"id":4`)
+	require.Equal(t, nil, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	c := newTestConfig()
+	c.QueuePersistPath = path
+	c.SendConcurrency = 1
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, c, n)
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	require.Equal(t, []string{"pending", "last"}, sentTitles(n))
+	msg := n.Sent()[0]
+	require.Equal(t, []int64{5}, msg.chatIds)
+	require.Equal(t, 7, msg.threadId)
+	require.Equal(t, true, msg.silent)
+	require.Equal(t, true, *msg.protectContent)
+}
+
+func TestQueuePersistOpenError(t *testing.T) {
+	c := newTestConfig()
+	c.QueuePersistPath = filepath.Join(t.TempDir(), "missing", "queue")
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, c, n)
+
+	// The unit works without persistence
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+	require.Equal(t, 1, len(n.Sent()))
+}
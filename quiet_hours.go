@@ -0,0 +1,214 @@
+package telegram_notifier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igulib/app"
+	"github.com/rs/zerolog"
+)
+
+// Quiet hours modes, see Config.QuietHoursMode.
+const (
+	// QuietHoursModeDefer holds the messages until quiet hours end.
+	QuietHoursModeDefer = "defer"
+
+	// QuietHoursModeDrop drops the messages.
+	QuietHoursModeDrop = "drop"
+)
+
+// DefaultQuietHoursMinLevel is the level of the log messages
+// delivered during quiet hours if QuietHours.MinLevel is empty.
+var DefaultQuietHoursMinLevel = "error"
+
+// QuietHours specifies the daily time window when non-urgent messages
+// are not delivered, see Config.QuietHoursMode.
+type QuietHours struct {
+	// Start and End specify the window as "HH:MM", e.g. "22:00" and "07:00".
+	// The window may wrap past midnight, the end is not included.
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+
+	// TimeZone specifies the IANA time zone name of Start and End,
+	// e.g. "Europe/Berlin". If empty, the local time zone is used.
+	TimeZone string `yaml:"time_zone" json:"time_zone"`
+
+	// MinLevel specifies the lowest level of the log messages
+	// that are delivered during quiet hours.
+	// If empty, DefaultQuietHoursMinLevel is used.
+	MinLevel string `yaml:"min_level" json:"min_level"`
+}
+
+type validatedQuietHours struct {
+	// start and end are minutes since midnight
+	start    int
+	end      int
+	location *time.Location
+	minLevel zerolog.Level
+}
+
+// parseQuietHours validates the quiet hours, nil means no quiet hours.
+func parseQuietHours(q *QuietHours) (*validatedQuietHours, error) {
+	if q == nil {
+		return nil, nil
+	}
+	var errs []error
+	v := &validatedQuietHours{location: time.Local}
+
+	parseClock := func(name, s string) int {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: bad %s %q, HH:MM expected", ErrBadQuietHours, name, s))
+			return 0
+		}
+		return t.Hour()*60 + t.Minute()
+	}
+	v.start = parseClock("start", q.Start)
+	v.end = parseClock("end", q.End)
+	if len(errs) == 0 && v.start == v.end {
+		errs = append(errs, fmt.Errorf("%w: start equals end", ErrBadQuietHours))
+	}
+
+	if q.TimeZone != "" {
+		loc, err := time.LoadLocation(q.TimeZone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", ErrBadQuietHours, err))
+		}
+		v.location = loc
+	}
+
+	minLevel := q.MinLevel
+	if minLevel == "" {
+		minLevel = DefaultQuietHoursMinLevel
+	}
+	level, ok := allowedLogLevels[strings.ToLower(strings.TrimSpace(minLevel))]
+	if !ok || level < zerolog.TraceLevel || level > zerolog.PanicLevel {
+		errs = append(errs, fmt.Errorf("%w: bad min level %q", ErrBadQuietHours, minLevel))
+	}
+	v.minLevel = level
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return v, nil
+}
+
+// contains returns true if t is within quiet hours.
+func (q *validatedQuietHours) contains(t time.Time) bool {
+	t = t.In(q.location)
+	m := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return m >= q.start && m < q.end
+	}
+	// The window wraps past midnight
+	return m >= q.start || m < q.end
+}
+
+// untilEnd returns the duration from t until quiet hours end.
+func (q *validatedQuietHours) untilEnd(t time.Time) time.Duration {
+	t = t.In(q.location)
+	end := time.Date(t.Year(), t.Month(), t.Day(), q.end/60, q.end%60, 0, 0, q.location)
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end.Sub(t)
+}
+
+// affects returns true if the message is not delivered during quiet hours:
+// the log messages below the min level and the messages without a level
+// unless they are urgent.
+func (q *validatedQuietHours) affects(msg TelegramMessage) bool {
+	return !msg.urgent && (!msg.hasLevel || msg.level < q.minLevel)
+}
+
+// quietHoursState holds the messages deferred until quiet hours end.
+type quietHoursState struct {
+	mu    sync.Mutex
+	held  []TelegramMessage
+	timer clockTimer
+}
+
+// applyQuietHours drops or holds the message if it is sent during quiet hours
+// and returns true in this case. Otherwise the messages held before
+// are returned to be enqueued ahead of the message.
+// Must be called with availabilityLock held.
+func (u *TelegramNotifier) applyQuietHours(msg TelegramMessage) (bool, []TelegramMessage) {
+	cfg := u.cfg()
+	q := cfg.QuietHours
+	s := &u.quietHours
+	if q == nil {
+		return false, nil
+	}
+	now := u.clock.Now()
+	if !q.contains(now) {
+		return false, s.takeAll()
+	}
+	if !q.affects(msg) {
+		return false, nil
+	}
+
+	if cfg.QuietHoursMode == QuietHoursModeDrop {
+		u.tgSuppressedCounter.Add(1)
+		return true, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.held) >= cfg.MsgBufSize {
+		s.held = s.held[1:]
+		u.tgDroppedCounter.Add(1)
+		u.internalLog().Warn().Msg("too many messages held during quiet hours, oldest message dropped")
+	}
+	s.held = append(s.held, msg)
+	if s.timer == nil {
+		s.timer = u.clock.AfterFunc(q.untilEnd(now), u.releaseQuietHours)
+	}
+	return true, nil
+}
+
+// takeAll returns the held messages and stops the release timer.
+func (s *quietHoursState) takeAll() []TelegramMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	held := s.held
+	s.held = nil
+	return held
+}
+
+// releaseQuietHours enqueues the messages held during quiet hours.
+// The messages remain held while the unit is not available.
+func (u *TelegramNotifier) releaseQuietHours() {
+	u.availabilityLock.Lock()
+	if u.availability != app.UAvailable {
+		u.quietHours.mu.Lock()
+		u.quietHours.timer = nil
+		u.quietHours.mu.Unlock()
+		u.availabilityLock.Unlock()
+		return
+	}
+	held := u.quietHours.takeAll()
+	for range held {
+		u.addRequest()
+	}
+	tgServiceDone := u.tgServiceDone
+	u.availabilityLock.Unlock()
+
+	u.enqueueReleased(held, tgServiceDone)
+}
+
+// enqueueReleased enqueues the messages released after quiet hours.
+// The request counter must be already incremented for the messages.
+func (u *TelegramNotifier) enqueueReleased(msgs []TelegramMessage, tgServiceDone chan struct{}) {
+	for _, msg := range msgs {
+		if err := u.enqueueRequest(msg, tgServiceDone); err != nil && !errors.Is(err, ErrMsgBufferFull) {
+			u.internalLog().Error().Err(err).Msg("failed to send message held during quiet hours")
+		}
+	}
+}
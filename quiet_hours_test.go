@@ -0,0 +1,125 @@
+package telegram_notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newQuietHoursTestNotifier(t *testing.T, mode string, n *fakeNotifier, clock *fakeClock) *TelegramNotifier {
+	c := newTestConfig()
+	c.LogLevels = []string{"all"}
+	c.SendConcurrency = 1
+	c.QuietHours = &QuietHours{Start: "22:30", End: "07:00", TimeZone: "Europe/Berlin"}
+	c.QuietHoursMode = mode
+	tn := newTestNotifier(t, c, n)
+	tn.setClock(clock)
+	return tn
+}
+
+func berlinTime(t *testing.T, hour, min int) time.Time {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.Equal(t, nil, err)
+	return time.Date(2024, 3, 5, hour, min, 0, 0, loc)
+}
+
+func TestQuietHoursDrop(t *testing.T) {
+	n := &fakeNotifier{}
+	clock := &fakeClock{now: berlinTime(t, 3, 0)}
+	tn := newQuietHoursTestNotifier(t, QuietHoursModeDrop, n, clock)
+	tn.UnitStart()
+
+	// Only urgent log messages are delivered during quiet hours
+	tn.ForwardLog(zerolog.InfoLevel, "info at night")
+	tn.ForwardLog(zerolog.ErrorLevel, "error at night")
+	require.Equal(t, nil, tn.SendAsync("async", "at night"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	// Synchronous and urgent messages are not affected
+	require.Equal(t, nil, tn.Send(context.Background(), "sync", "at night"))
+	require.Equal(t, nil, tn.SendUrgent("urgent", "at night"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"ERROR", "sync", "urgent"}, sentTitles(n))
+	require.Equal(t, uint64(2), tn.Stats().Suppressed)
+
+	clock.Set(berlinTime(t, 7, 0))
+	tn.ForwardLog(zerolog.InfoLevel, "info in the morning")
+	require.Equal(t, nil, tn.SendAsync("async", "in the morning"))
+	tn.UnitQuit()
+	require.Equal(t, []string{"ERROR", "sync", "urgent", "INFO", "async"}, sentTitles(n))
+}
+
+func TestQuietHoursDefer(t *testing.T) {
+	n := &fakeNotifier{}
+	clock := &fakeClock{now: berlinTime(t, 23, 15)}
+	tn := newQuietHoursTestNotifier(t, QuietHoursModeDefer, n, clock)
+	tn.UnitStart()
+
+	tn.ForwardLog(zerolog.WarnLevel, "warning at night")
+	require.Equal(t, nil, tn.SendAsync("async", "at night"))
+	tn.ForwardLog(zerolog.FatalLevel, "fatal at night")
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"FATAL"}, sentTitles(n))
+
+	// The held messages are sent ahead of the first message
+	// after quiet hours end
+	clock.Set(berlinTime(t, 22, 29))
+	require.Equal(t, nil, tn.SendAsync("evening", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"FATAL", "WARNING", "async", "evening"}, sentTitles(n))
+
+	// The held messages are sent when the unit quits
+	clock.Set(berlinTime(t, 6, 59))
+	require.Equal(t, nil, tn.SendAsync("morning", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, 4, len(n.Sent()))
+	tn.UnitQuit()
+	require.Equal(t, []string{"FATAL", "WARNING", "async", "evening", "morning"}, sentTitles(n))
+}
+
+func TestQuietHoursWindow(t *testing.T) {
+	q, err := parseQuietHours(&QuietHours{Start: "22:30", End: "07:00", TimeZone: "Europe/Berlin"})
+	require.Equal(t, nil, err)
+	require.Equal(t, zerolog.ErrorLevel, q.minLevel)
+	require.Equal(t, true, q.contains(berlinTime(t, 22, 30)))
+	require.Equal(t, true, q.contains(berlinTime(t, 0, 0)))
+	require.Equal(t, true, q.contains(berlinTime(t, 6, 59)))
+	require.Equal(t, false, q.contains(berlinTime(t, 7, 0)))
+	require.Equal(t, false, q.contains(berlinTime(t, 22, 29)))
+	// The time zone of the checked time doesn't matter
+	require.Equal(t, true, q.contains(berlinTime(t, 23, 0).UTC()))
+	require.Equal(t, 7*time.Hour+45*time.Minute, q.untilEnd(berlinTime(t, 23, 15)))
+	require.Equal(t, time.Hour, q.untilEnd(berlinTime(t, 6, 0)))
+
+	q, err = parseQuietHours(&QuietHours{Start: "12:00", End: "13:30", MinLevel: "warning"})
+	require.Equal(t, nil, err)
+	require.Equal(t, zerolog.WarnLevel, q.minLevel)
+	day := time.Date(2024, 3, 5, 12, 0, 0, 0, time.Local)
+	require.Equal(t, true, q.contains(day))
+	require.Equal(t, true, q.contains(day.Add(89*time.Minute)))
+	require.Equal(t, false, q.contains(day.Add(90*time.Minute)))
+	require.Equal(t, false, q.contains(day.Add(-time.Minute)))
+}
+
+func TestQuietHoursValidation(t *testing.T) {
+	for _, q := range []QuietHours{
+		{Start: "22", End: "07:00"},
+		{Start: "22:00", End: "25:00"},
+		{Start: "22:00", End: "22:00"},
+		{Start: "22:00", End: "07:00", TimeZone: "Mars/Olympus"},
+		{Start: "22:00", End: "07:00", MinLevel: "urgent"},
+	} {
+		c := newTestConfig()
+		c.QuietHours = &q
+		_, err := New(t.Name(), c)
+		require.ErrorIs(t, err, ErrBadQuietHours, q)
+	}
+
+	c := newTestConfig()
+	c.QuietHours = &QuietHours{Start: "22:00", End: "07:00"}
+	c.QuietHoursMode = "queue"
+	_, err := New(t.Name(), c)
+	require.ErrorIs(t, err, ErrBadQuietHoursMode)
+}
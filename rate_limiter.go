@@ -0,0 +1,174 @@
+package telegram_notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket rate limiter, it is thread-safe.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(ratePerSec float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   ratePerSec,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is acquired or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Reserve the token even if it is not available yet,
+	// subsequent callers will wait longer.
+	l.tokens--
+	if l.tokens >= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Return the reserved token
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// botRateLimiter limits the rate of messages sent by a single Telegram bot
+// both globally and per chat.
+type botRateLimiter struct {
+	global *rateLimiter
+
+	perChatRate float64
+	chatsLock   sync.Mutex
+	chats       map[int64]*rateLimiter
+}
+
+// Wait blocks until the message can be sent to all the specified chats
+// without exceeding the rate limits or ctx is done.
+func (l *botRateLimiter) Wait(ctx context.Context, chatIds []int64) error {
+	if l.global != nil {
+		if err := l.global.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.perChatRate <= 0 {
+		return nil
+	}
+	for _, id := range chatIds {
+		if err := l.chat(id).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *botRateLimiter) chat(id int64) *rateLimiter {
+	l.chatsLock.Lock()
+	defer l.chatsLock.Unlock()
+	cl, ok := l.chats[id]
+	if !ok {
+		cl = newRateLimiter(l.perChatRate, 1)
+		l.chats[id] = cl
+	}
+	return cl
+}
+
+// botRateLimiterKey identifies the rate limiter shared by the units
+// that use the same bot token with the same limits.
+type botRateLimiterKey struct {
+	botToken                 string
+	maxMessagesPerSec        int
+	maxMessagesPerChatPerSec float64
+}
+
+func newBotRateLimiterKey(c *validatedConfig) botRateLimiterKey {
+	return botRateLimiterKey{
+		botToken:                 c.BotToken,
+		maxMessagesPerSec:        c.MaxMessagesPerSec,
+		maxMessagesPerChatPerSec: c.MaxMessagesPerChatPerSec,
+	}
+}
+
+type sharedBotRateLimiter struct {
+	limiter *botRateLimiter
+	refs    int
+}
+
+var (
+	botRateLimitersLock sync.Mutex
+
+	// botRateLimiters are shared by all units using the same bot token
+	// because Telegram rate limits apply per bot. The units configured
+	// with different limits for the same token use separate limiters.
+	botRateLimiters = make(map[botRateLimiterKey]*sharedBotRateLimiter)
+)
+
+// getBotRateLimiter returns the rate limiter shared by all units
+// that use the bot token and the limits from the specified config.
+// The limiter must be released with releaseBotRateLimiter
+// when the unit no longer uses it.
+func getBotRateLimiter(c *validatedConfig) *botRateLimiter {
+	botRateLimitersLock.Lock()
+	defer botRateLimitersLock.Unlock()
+
+	key := newBotRateLimiterKey(c)
+	l, ok := botRateLimiters[key]
+	if !ok {
+		l = &sharedBotRateLimiter{limiter: &botRateLimiter{
+			perChatRate: c.MaxMessagesPerChatPerSec,
+			chats:       make(map[int64]*rateLimiter),
+		}}
+		if c.MaxMessagesPerSec > 0 {
+			l.limiter.global = newRateLimiter(float64(c.MaxMessagesPerSec), c.MaxMessagesPerSec)
+		}
+		botRateLimiters[key] = l
+	}
+	l.refs++
+	return l.limiter
+}
+
+// releaseBotRateLimiter releases the rate limiter returned by
+// getBotRateLimiter for the specified config. The limiter is removed
+// when no unit uses it.
+func releaseBotRateLimiter(c *validatedConfig) {
+	botRateLimitersLock.Lock()
+	defer botRateLimitersLock.Unlock()
+
+	key := newBotRateLimiterKey(c)
+	l, ok := botRateLimiters[key]
+	if !ok {
+		return
+	}
+	l.refs--
+	if l.refs <= 0 {
+		delete(botRateLimiters, key)
+	}
+}
@@ -0,0 +1,72 @@
+package telegram_notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(20, 2)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		require.Equal(t, nil, l.Wait(context.Background()))
+	}
+	// 2 tokens are available immediately, 4 more take 200ms at 20 per second
+	require.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newRateLimiter(0.001, 1)
+	require.Equal(t, nil, l.Wait(ctx), "first token must be available immediately")
+	require.ErrorIs(t, l.Wait(ctx), context.Canceled)
+}
+
+func TestBotRateLimiter(t *testing.T) {
+	c := newTestConfig()
+	c.BotToken = "654321:TestBotRateLimiter_TestBotRateLimiter"
+	c.MaxMessagesPerSec = 100
+	c.MaxMessagesPerChatPerSec = 10
+
+	tn1, err := New("TestBotRateLimiter1", c)
+	require.Equal(t, nil, err)
+	tn2, err := New("TestBotRateLimiter2", c)
+	require.Equal(t, nil, err)
+	require.Same(t, tn1.rateLimiter.Load(), tn2.rateLimiter.Load(), "units with the same bot token must share the rate limiter")
+
+	other, err := New("TestBotRateLimiterOther", newTestConfig())
+	require.Equal(t, nil, err)
+	require.NotSame(t, tn1.rateLimiter.Load(), other.rateLimiter.Load())
+
+	// The units with different limits for the same token don't share the limiter
+	c2 := *c
+	c2.MaxMessagesPerSec = 1
+	tn3, err := New("TestBotRateLimiter3", &c2)
+	require.Equal(t, nil, err)
+	require.NotSame(t, tn1.rateLimiter.Load(), tn3.rateLimiter.Load())
+
+	// Reconfigure applies the new limits and releases the old limiter
+	// when no unit uses it
+	oldKey := newBotRateLimiterKey(tn3.cfg())
+	c2.MaxMessagesPerSec = 100
+	require.Equal(t, nil, tn3.Reconfigure(&c2))
+	require.Same(t, tn1.rateLimiter.Load(), tn3.rateLimiter.Load())
+	botRateLimitersLock.Lock()
+	_, ok := botRateLimiters[oldKey]
+	require.Equal(t, false, ok, "unused limiter must be removed")
+	require.Equal(t, 3, botRateLimiters[newBotRateLimiterKey(tn1.cfg())].refs)
+	botRateLimitersLock.Unlock()
+
+	// Per-chat limit applies to each chat separately
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.Equal(t, nil, tn1.rateLimiter.Load().Wait(context.Background(), []int64{1}))
+		require.Equal(t, nil, tn1.rateLimiter.Load().Wait(context.Background(), []int64{2}))
+	}
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 190*time.Millisecond)
+	require.Less(t, elapsed, time.Second)
+}
@@ -0,0 +1,73 @@
+package telegram_notifier
+
+import (
+	"context"
+	"sync"
+)
+
+// testModeBotToken is the placeholder bot token of NewTestNotifier,
+// it is never sent to Telegram.
+const testModeBotToken = "0:TESTtestTESTtestTESTtestTESTtest00"
+
+// RecordedSink records the messages delivered by the TelegramNotifier
+// created with NewTestNotifier instead of sending them to Telegram.
+// It is safe for concurrent sends.
+type RecordedSink struct {
+	mu       sync.Mutex
+	messages []TelegramMessage
+}
+
+// Send implements MessageSender.
+func (s *RecordedSink) Send(ctx context.Context, subject, message string) error {
+	return s.sendMessage(ctx, TelegramMessage{Title: subject, Text: message})
+}
+
+// sendMessage implements messageOptionsSender.
+func (s *RecordedSink) sendMessage(ctx context.Context, msg TelegramMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msg.ctx = nil
+	msg.chatIds = append([]int64(nil), msg.chatIds...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns the recorded messages in the order they were sent,
+// it is thread-safe. Long messages are recorded split into parts.
+func (s *RecordedSink) Messages() []TelegramMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]TelegramMessage(nil), s.messages...)
+}
+
+// Reset removes the recorded messages, it is thread-safe.
+func (s *RecordedSink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// NewTestNotifier creates a TelegramNotifier for tests that records
+// the messages to the returned RecordedSink instead of sending them
+// to Telegram, so neither a bot token nor network access is required.
+// The messages are sent to the chat with ID 1, rate limits are disabled.
+// The log forwarding settings can be changed with the setters,
+// e.g. SetLogLevels.
+func NewTestNotifier(unitName string) (*TelegramNotifier, *RecordedSink) {
+	u, err := New(unitName, &Config{
+		BotToken:                 testModeBotToken,
+		ChatIds:                  []int64{1},
+		MaxMessagesPerSec:        -1,
+		MaxMessagesPerChatPerSec: -1,
+	})
+	if err != nil {
+		// The config is valid
+		panic(err)
+	}
+	sink := &RecordedSink{}
+	u.SetSender(sink)
+	return u, sink
+}
@@ -0,0 +1,40 @@
+package telegram_notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTestNotifier(t *testing.T) {
+	tn, sink := NewTestNotifier(t.Name())
+	require.Equal(t, nil, tn.SetLogLevels([]string{"error"}))
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	require.Equal(t, nil, tn.SendSilent("silent", "text"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.ForwardLog(zerolog.ErrorLevel, "disk full")
+	tn.ForwardLog(zerolog.InfoLevel, "not forwarded")
+	require.Equal(t, nil, tn.Flush(context.Background()))
+
+	messages := sink.Messages()
+	require.Equal(t, 3, len(messages))
+	require.Equal(t, "title", messages[0].Title)
+	require.Equal(t, "text", messages[0].Text)
+	require.Equal(t, []int64{1}, messages[0].chatIds)
+	require.Equal(t, true, messages[1].silent)
+	require.Equal(t, "ERROR", messages[2].Title)
+	require.Contains(t, messages[2].Text, "disk full")
+
+	sink.Reset()
+	require.Equal(t, 0, len(sink.Messages()))
+	require.Equal(t, nil, tn.Send(context.Background(), "after reset", "text"))
+	require.Equal(t, 1, len(sink.Messages()))
+
+	tn.UnitQuit()
+}
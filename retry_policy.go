@@ -0,0 +1,47 @@
+package telegram_notifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryPolicy overrides the configured retries of a single message,
+// see MessageOptions.Retry. Zero-valued fields fall back to the config.
+type RetryPolicy struct {
+	// MaxAttempts specifies the maximum number of send attempts
+	// including the first one, 1 disables retries.
+	// If zero, Config.MaxRetries is used.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+
+	// BaseDelay specifies the delay before the first retry,
+	// it is doubled for each subsequent retry.
+	// If zero, Config.RetryBaseDelayMs is used.
+	BaseDelay time.Duration `json:"base_delay,omitempty"`
+
+	// MaxDelay caps the delay between retries. If zero, it is not capped.
+	MaxDelay time.Duration `json:"max_delay,omitempty"`
+}
+
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 || p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("%w: negative values are not allowed", ErrBadRetryPolicy)
+	}
+	return nil
+}
+
+// retrySettings returns the maximum number of retries, the base delay
+// and the maximum delay of the message according to its retry policy.
+func (v *validatedConfig) retrySettings(msg TelegramMessage) (int, time.Duration, time.Duration) {
+	maxRetries, baseDelay := v.MaxRetries, v.RetryBaseDelay
+	p := msg.retry
+	if p == nil {
+		return maxRetries, baseDelay, 0
+	}
+	if p.MaxAttempts > 0 {
+		maxRetries = p.MaxAttempts - 1
+	}
+	if p.BaseDelay > 0 {
+		baseDelay = p.BaseDelay
+	}
+	return maxRetries, baseDelay, p.MaxDelay
+}
@@ -0,0 +1,82 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendWithOptionsRetryPolicy(t *testing.T) {
+	failure := errors.New("Internal Server Error")
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MaxRetries = -1
+	c.RetryBaseDelayMs = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	send := func(title string, retry *RetryPolicy, failures int) {
+		t.Helper()
+		n.mu.Lock()
+		n.calls = 0
+		n.failures = nil
+		for i := 0; i < failures; i++ {
+			n.failures = append(n.failures, failure)
+		}
+		n.mu.Unlock()
+		require.Equal(t, nil, tn.SendWithOptions(context.Background(), MessageOptions{
+			Title: title, Text: "text", Retry: retry,
+		}))
+		require.Equal(t, nil, tn.Flush(context.Background()))
+	}
+
+	// The configured retries are disabled
+	send("default", nil, 1)
+	require.Equal(t, 1, n.Calls())
+
+	send("aggressive", &RetryPolicy{MaxAttempts: 5}, 4)
+	require.Equal(t, 5, n.Calls())
+
+	send("exhausted", &RetryPolicy{MaxAttempts: 3}, 3)
+	require.Equal(t, 3, n.Calls())
+
+	// The delay is capped
+	start := time.Now()
+	send("capped", &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Millisecond}, 2)
+	require.Equal(t, 3, n.Calls())
+	require.Less(t, time.Since(start), 10*time.Second)
+
+	tn.UnitQuit()
+	require.Equal(t, []string{"aggressive", "capped"}, sentTitles(n))
+	require.Equal(t, uint64(2), tn.Stats().Failed)
+	require.Equal(t, uint64(8), tn.Stats().Retried)
+}
+
+func TestRetryPolicyOverridesConfig(t *testing.T) {
+	n := &fakeNotifier{failures: []error{errors.New("Internal Server Error")}}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.RetryBaseDelayMs = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	// Routine messages are not retried
+	require.Equal(t, nil, tn.SendWithOptions(context.Background(), MessageOptions{
+		Title: "routine", Text: "text", Retry: &RetryPolicy{MaxAttempts: 1},
+	}))
+	tn.UnitQuit()
+	require.Equal(t, 1, n.Calls())
+	require.Equal(t, 0, len(n.Sent()))
+
+	err := tn.SendWithOptions(context.Background(), MessageOptions{
+		Title: "title", Text: "text", Retry: &RetryPolicy{MaxAttempts: -1},
+	})
+	require.ErrorIs(t, err, ErrBadRetryPolicy)
+}
@@ -0,0 +1,189 @@
+package telegram_notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/igulib/app"
+)
+
+// BatchError is returned by SendBatch if some of the messages
+// are not enqueued.
+type BatchError struct {
+	// Indices are the indices of the messages that are not enqueued.
+	Indices []int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("failed to enqueue messages %v of the batch: %v", e.Indices, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// batchedMessage is the message of SendBatch with the indices
+// of the messages combined into it.
+type batchedMessage struct {
+	msg     TelegramMessage
+	indices []int
+}
+
+// SendBatch asynchronously sends the messages in order, it is thread-safe.
+// Only Title and Text of the messages are used.
+// If BatchIntervalMs is set, the messages with the same title are combined
+// into as few messages as MaxMessageLength allows, the same way as
+// the messages sent within the batch interval: the order of the messages
+// with the same title is preserved, the combined messages follow
+// in the order of the first message of each title.
+// With the "drop_newest" and "drop_oldest" overflow policies
+// the batch is enqueued all-or-nothing: if the message buffer doesn't
+// have room for the whole batch, no message is enqueued and *BatchError
+// wrapping ErrMsgBufferFull is returned. The room is reserved at once,
+// so the messages enqueued concurrently can't make the batch partially
+// enqueued. With the "block" policy
+// SendBatch blocks until all messages are enqueued.
+func (u *TelegramNotifier) SendBatch(msgs []TelegramMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	cfg := u.cfg()
+	batch := make([]batchedMessage, 0, len(msgs))
+	for i, m := range msgs {
+		batch = append(batch, batchedMessage{
+			msg:     TelegramMessage{Title: m.Title, Text: m.Text, ctx: context.Background()},
+			indices: []int{i},
+		})
+	}
+	if cfg.BatchInterval > 0 {
+		batch = combineBatch(batch, cfg.MaxMessageLength, cfg.TitleMode)
+	}
+
+	u.availabilityLock.Lock()
+	if u.availability != app.UAvailable {
+		u.availabilityLock.Unlock()
+		return ErrUnitNotAvailable
+	}
+
+	// The same filters as for a single message apply
+	var enqueued []batchedMessage
+	var released []TelegramMessage
+	dedupWindow := cfg.DedupWindow
+	for _, b := range batch {
+		if dedupWindow > 0 && u.dedup.suppress(u, b.msg, dedupWindow) {
+			continue
+		}
+		held, r := u.applyQuietHours(b.msg)
+		released = append(released, r...)
+		if held {
+			continue
+		}
+		enqueued = append(enqueued, b)
+	}
+	for range released {
+		u.addRequest()
+	}
+	// The combined messages are counted as many
+	for _, b := range enqueued {
+		for range b.indices {
+			u.addRequest()
+		}
+	}
+	tgServiceDone := u.tgServiceDone
+	u.availabilityLock.Unlock()
+
+	// The released messages are not a part of the batch
+	u.enqueueReleased(released, tgServiceDone)
+	if cfg.OverflowPolicy == OverflowPolicyBlock {
+		var failed []int
+		for _, b := range enqueued {
+			if err := u.enqueueRequest(b.msg, tgServiceDone); err != nil {
+				failed = append(failed, b.indices...)
+			}
+		}
+		if len(failed) > 0 {
+			return &BatchError{Indices: failed, Err: ErrUnitNotAvailable}
+		}
+		return nil
+	}
+
+	// No other message can be enqueued until the batch is,
+	// while the workers can only make more room
+	u.tgEnqueueLock.Lock()
+	defer u.tgEnqueueLock.Unlock()
+	room := cap(u.tgMsgChan)
+	if cfg.OverflowPolicy == OverflowPolicyDropNewest {
+		room -= len(u.tgMsgChan)
+	}
+	if len(enqueued) > room {
+		for _, b := range enqueued {
+			u.doneMessage(b.msg)
+		}
+		u.tgDroppedCounter.Add(uint64(len(msgs)))
+		u.internalLog().Warn().Int("messages", len(msgs)).Msg("message buffer full, batch dropped")
+		return &BatchError{Indices: allIndices(len(msgs)), Err: ErrMsgBufferFull}
+	}
+	var failed []int
+	for _, b := range enqueued {
+		b.msg.enqueuedAt = u.clock.Now()
+		b.msg = u.persistMessage(b.msg)
+		if err := u.enqueueNonBlocking(b.msg, tgServiceDone); err != nil {
+			failed = append(failed, b.indices...)
+		}
+	}
+	if len(failed) > 0 {
+		return &BatchError{Indices: failed, Err: ErrUnitNotAvailable}
+	}
+	return nil
+}
+
+// combineBatch groups the messages by title in the order of the first
+// message of each group and joins the texts of each group with line breaks
+// the same way as combineMessages does. A combined message doesn't exceed maxLen.
+func combineBatch(batch []batchedMessage, maxLen int, titleMode string) []batchedMessage {
+	var titles []string
+	groups := make(map[string][]batchedMessage)
+	for _, b := range batch {
+		if _, ok := groups[b.msg.Title]; !ok {
+			titles = append(titles, b.msg.Title)
+		}
+		groups[b.msg.Title] = append(groups[b.msg.Title], b)
+	}
+
+	var r []batchedMessage
+	for _, title := range titles {
+		var text strings.Builder
+		length := 0
+		for _, b := range groups[title] {
+			msgLen := utf8.RuneCountInString(b.msg.Text)
+			if n := len(r); n > 0 && r[n-1].msg.Title == title && length+1+msgLen <= maxLen {
+				last := &r[n-1]
+				text.WriteByte('\n')
+				text.WriteString(b.msg.Text)
+				last.msg.Text = text.String()
+				last.msg.batched++
+				last.indices = append(last.indices, b.indices...)
+				length += 1 + msgLen
+				continue
+			}
+			r = append(r, b)
+			text.Reset()
+			text.WriteString(b.msg.Text)
+			length = composedTitleLen(title, titleMode) + msgLen
+		}
+	}
+	return r
+}
+
+func allIndices(n int) []int {
+	r := make([]int, n)
+	for i := range r {
+		r[i] = i
+	}
+	return r
+}
@@ -0,0 +1,166 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendBatch(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	require.ErrorIs(t, tn.SendBatch([]TelegramMessage{{Title: "title"}}), ErrUnitNotAvailable)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// Without batching the messages are sent one by one in order
+	require.Equal(t, nil, tn.SendBatch(nil))
+	require.Equal(t, nil, tn.SendBatch([]TelegramMessage{
+		{Title: "A", Text: "1"},
+		{Title: "B", Text: "2"},
+		{Title: "A", Text: "3"},
+	}))
+	tn.UnitQuit()
+	require.Equal(t, []string{"A", "B", "A"}, sentTitles(n))
+	require.Equal(t, []string{"1", "2", "3"}, sentTexts(n))
+}
+
+func TestSendBatchCombined(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.BatchIntervalMs = 50
+	c.MaxMessageLength = 21
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendBatch([]TelegramMessage{
+		{Title: "ERROR", Text: "error 1"},
+		{Title: "INFO", Text: "info 1"},
+		{Title: "ERROR", Text: "error 2"},
+		{Title: "ERROR", Text: "error 3"},
+		{Title: "INFO", Text: "info 2"},
+	}))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	// "ERROR\nerror 1\nerror 2\nerror 3" would exceed 21 characters
+	require.Equal(t, []string{"ERROR", "ERROR", "INFO"}, sentTitles(n))
+	require.Equal(t, []string{"error 1\nerror 2", "error 3", "info 1\ninfo 2"}, sentTexts(n))
+	require.Equal(t, uint64(5), tn.Stats().Sent)
+}
+
+func TestSendBatchOverflow(t *testing.T) {
+	t.Run("drop_newest", func(t *testing.T) {
+		tn, n := fillMsgBuffer(t, OverflowPolicyDropNewest)
+
+		// No message is enqueued if the batch doesn't fit
+		err := tn.SendBatch([]TelegramMessage{{Title: "4"}, {Title: "5"}})
+		require.ErrorIs(t, err, ErrMsgBufferFull)
+		var batchErr *BatchError
+		require.Equal(t, true, errors.As(err, &batchErr))
+		require.Equal(t, []int{0, 1}, batchErr.Indices)
+		require.Equal(t, 2, len(tn.tgMsgChan))
+
+		require.Equal(t, nil, tn.Flush(context.Background()))
+		tn.UnitQuit()
+		require.Equal(t, []string{"1", "2", "3"}, sentTitles(n))
+		require.Equal(t, uint64(2), tn.Stats().Dropped)
+	})
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		tn, n := fillMsgBuffer(t, OverflowPolicyDropOldest)
+
+		// The batch larger than the buffer is dropped
+		err := tn.SendBatch([]TelegramMessage{{Title: "4"}, {Title: "5"}, {Title: "6"}})
+		require.ErrorIs(t, err, ErrMsgBufferFull)
+
+		// The batch replaces the oldest messages
+		require.Equal(t, nil, tn.SendBatch([]TelegramMessage{{Title: "7"}, {Title: "8"}}))
+
+		require.Equal(t, nil, tn.Flush(context.Background()))
+		tn.UnitQuit()
+		require.Equal(t, []string{"1", "7", "8"}, sentTitles(n))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		tn, n := fillMsgBuffer(t, OverflowPolicyBlock)
+
+		// The batch waits for room in the buffer
+		require.Equal(t, nil, tn.SendBatch([]TelegramMessage{{Title: "4"}, {Title: "5"}}))
+
+		require.Equal(t, nil, tn.Flush(context.Background()))
+		tn.UnitQuit()
+		require.Equal(t, "1,2,3,4,5", strings.Join(sentTitles(n), ","))
+	})
+}
+
+// gatedClock blocks the next calls to Now until the test releases them.
+type gatedClock struct {
+	realClock
+	gated   atomic.Int32
+	entered chan chan struct{}
+}
+
+func (c *gatedClock) Now() time.Time {
+	if c.gated.Add(-1) >= 0 {
+		release := make(chan struct{})
+		c.entered <- release
+		<-release
+	}
+	return time.Now()
+}
+
+func TestSendBatchConcurrentSenders(t *testing.T) {
+	n := &fakeNotifier{delay: 300 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MsgBufSize = 3
+	c.OverflowPolicy = OverflowPolicyDropNewest
+	tn := newTestNotifier(t, c, n)
+	clk := &gatedClock{entered: make(chan chan struct{})}
+	tn.setClock(clk)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Eventually(t, func() bool {
+		return len(tn.tgMsgChan) == 0
+	}, time.Second, time.Millisecond, "the worker must take the first message")
+
+	// The single message is enqueued while the batch
+	// is being enqueued after the room check
+	clk.gated.Store(2)
+	singleErr := make(chan error)
+	go func() {
+		singleErr <- tn.SendAsync("2", "text")
+	}()
+	releaseSingle := <-clk.entered
+	batchErr := make(chan error)
+	go func() {
+		batchErr <- tn.SendBatch([]TelegramMessage{{Title: "b"}, {Title: "b"}, {Title: "b"}})
+	}()
+	releaseBatch := <-clk.entered
+	close(releaseSingle)
+	time.Sleep(10 * time.Millisecond)
+	close(releaseBatch)
+
+	// The batch is enqueued as a whole
+	require.Equal(t, nil, <-batchErr)
+	require.ErrorIs(t, <-singleErr, ErrMsgBufferFull)
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+	require.Equal(t, []string{"1", "b", "b", "b"}, sentTitles(n))
+}
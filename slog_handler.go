@@ -0,0 +1,74 @@
+//go:build go1.21
+
+package telegram_notifier
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// SlogHandler returns a slog.Handler that sends the log records
+// having the configured log levels and prefixes to Telegram
+// the same way as the zerolog hook does.
+// Only the record message is sent, the attributes are ignored.
+// All records are also passed to the next handler if it is not nil.
+func (u *TelegramNotifier) SlogHandler(next slog.Handler) slog.Handler {
+	return &slogHandler{u: u, next: next}
+}
+
+type slogHandler struct {
+	u    *TelegramNotifier
+	next slog.Handler
+}
+
+// slogLevelToZerolog maps slog levels to zerolog levels,
+// the levels between the standard ones are rounded down.
+func slogLevelToZerolog(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	case level >= slog.LevelDebug:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.u.cfg().acceptsLogLevel(slogLevelToZerolog(level)) {
+		return true
+	}
+	return h.next != nil && h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.u.forwardLog(slogLevelToZerolog(r.Level), r.Message)
+	if h.next != nil && h.next.Enabled(ctx, r.Level) {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.next == nil {
+		return h
+	}
+	return &slogHandler{u: h.u, next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if h.next == nil {
+		return h
+	}
+	return &slogHandler{u: h.u, next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,111 @@
+//go:build go1.21
+
+package telegram_notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogHandler(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error", "warning"}
+	c.LogOnlyWithPrefixes = []string{"[tg]"}
+	c.LogDateTime = true
+	c.LogUseUTC = true
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	var buf bytes.Buffer
+	next := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := slog.New(tn.SlogHandler(next)).With("key", "value")
+
+	logger.Error("[tg] error 1")
+	logger.Warn("[tg] warning 1", "attr", 1)
+	logger.Info("[tg] info 1")
+	logger.Debug("[tg] debug 1")
+	logger.Error("error 2")
+	logger.Log(context.Background(), slog.LevelError+4, "[tg] critical 1")
+
+	tn.UnitQuit()
+
+	var titles, texts []string
+	for _, m := range n.Sent() {
+		titles = append(titles, m.Title)
+		texts = append(texts, m.Text)
+	}
+	require.Equal(t, []string{"ERROR", "WARNING", "ERROR"}, titles)
+	require.Equal(t, 3, len(texts))
+	for i, prefix := range []string{"[tg] error 1", "[tg] warning 1", "[tg] critical 1"} {
+		require.Regexp(t, "^"+regexp.QuoteMeta(prefix+" | ")+".*Z$", texts[i])
+		_, err := time.Parse(time.RFC3339, texts[i][len(prefix)+3:])
+		require.Equal(t, nil, err)
+	}
+
+	// All records enabled by the next handler are passed to it with attributes
+	out := buf.String()
+	require.Contains(t, out, `msg="[tg] info 1" key=value`)
+	require.Contains(t, out, `msg="error 2" key=value`)
+	require.Contains(t, out, `msg="[tg] warning 1" key=value attr=1`)
+	require.NotContains(t, out, "debug 1")
+}
+
+func TestSlogHandlerWithoutNext(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"info"}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	h := tn.SlogHandler(nil)
+	require.Equal(t, true, h.Enabled(context.Background(), slog.LevelInfo))
+	require.Equal(t, false, h.Enabled(context.Background(), slog.LevelDebug))
+	require.Equal(t, false, h.Enabled(context.Background(), slog.LevelError))
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := slog.New(h).WithGroup("group").With("key", "value")
+	logger.Info("info 1")
+	logger.Error("error 1")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 1, len(sent))
+	require.Equal(t, "INFO", sent[0].Title)
+	require.Equal(t, "info 1", sent[0].Text)
+}
+
+func TestSlogHandlerCaller(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.IncludeCallerForLevels = []string{"error"}
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := slog.New(tn.SlogHandler(nil))
+	_, _, line, _ := runtime.Caller(0)
+	logger.Error("error")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 1, len(sent))
+	require.Equal(t, fmt.Sprintf("error | caller=slog_handler_test.go:%d", line+1), sent[0].Text)
+}
@@ -0,0 +1,75 @@
+package telegram_notifier
+
+// Stats contains the TelegramNotifier counters since the unit was created.
+type Stats struct {
+	// Sent is the number of messages successfully sent.
+	Sent uint64
+
+	// Failed is the number of messages that were not sent
+	// due to an error, after all retries for asynchronous messages.
+	// Messages cancelled by the sender are not counted.
+	Failed uint64
+
+	// Dropped is the number of messages dropped because
+	// the message buffer was full, see Config.OverflowPolicy,
+	// or they waited too long, see Config.MaxMessageAgeSec.
+	Dropped uint64
+
+	// Retried is the number of retries of failed sends.
+	Retried uint64
+
+	// Suppressed is the number of copies of messages suppressed
+	// by deduplication, see Config.DedupWindowMs, of the messages
+	// dropped during quiet hours, see Config.QuietHours, and of
+	// the messages not sent because all their chats are muted,
+	// see MuteChat.
+	Suppressed uint64
+
+	// FallbackSent is the number of failed messages delivered
+	// by a fallback service, see Config.FallbackServices.
+	// They are counted as Failed as well.
+	FallbackSent uint64
+
+	// Queued is the number of messages currently waiting in the buffers.
+	Queued int
+
+	// BreakerState is the circuit breaker state: BreakerStateClosed,
+	// BreakerStateOpen or BreakerStateHalfOpen, see Config.FailureThreshold.
+	BreakerState string
+
+	// ConnectivityLost is true while sending is paused until
+	// Telegram Bot API is reachable, see Config.ConnectivityFailureThreshold.
+	ConnectivityLost bool
+}
+
+// Stats returns the current counters, it is thread-safe.
+// The counters are read independently and may be slightly
+// inconsistent with each other while messages are being sent.
+func (u *TelegramNotifier) Stats() Stats {
+	return Stats{
+		Sent:             u.tgSentCounter.Load(),
+		Failed:           u.tgFailedCounter.Load(),
+		Dropped:          u.tgDroppedCounter.Load(),
+		Retried:          u.tgRetriedCounter.Load(),
+		Suppressed:       u.tgSuppressedCounter.Load(),
+		FallbackSent:     u.tgFallbackCounter.Load(),
+		Queued:           len(u.tgMsgChan) + len(u.tgUrgentChan),
+		BreakerState:     u.breakerState(),
+		ConnectivityLost: u.connectivityLost(),
+	}
+}
+
+// QueueLen returns the number of messages waiting in the message buffer,
+// it is thread-safe and lock-free. The value is a point-in-time snapshot
+// that may change immediately. The urgent messages are not included,
+// see Stats().Queued.
+func (u *TelegramNotifier) QueueLen() int {
+	return len(u.tgMsgChan)
+}
+
+// QueueCap returns the capacity of the message buffer, see Config.MsgBufSize.
+// QueueLen() == QueueCap() means the buffer is full and new messages
+// are handled according to Config.OverflowPolicy.
+func (u *TelegramNotifier) QueueCap() int {
+	return cap(u.tgMsgChan)
+}
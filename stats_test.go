@@ -0,0 +1,104 @@
+package telegram_notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	transient := errors.New("connection reset")
+	permanent := errors.New("Bad Request: chat not found")
+	n := &fakeNotifier{
+		// First message is retried once and sent,
+		// second fails permanently
+		failures: []error{transient, permanent},
+	}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.RetryBaseDelayMs = 1
+	tn := newTestNotifier(t, c, n)
+
+	require.Equal(t, Stats{BreakerState: BreakerStateClosed}, tn.Stats())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		require.Equal(t, nil, tn.SendAsync("title", "text"))
+	}
+	require.Eventually(t, func() bool {
+		s := tn.Stats()
+		return s.Sent+s.Failed == total
+	}, 5*time.Second, 10*time.Millisecond)
+
+	tn.UnitQuit()
+
+	s := tn.Stats()
+	require.Equal(t, uint64(total-1), s.Sent)
+	require.Equal(t, uint64(1), s.Failed)
+	require.Equal(t, uint64(1), s.Retried)
+	require.Equal(t, uint64(0), s.Dropped)
+	require.Equal(t, 0, s.Queued)
+	require.Equal(t, s.Failed+s.Dropped, tn.DroppedMessages())
+	require.Equal(t, int(s.Sent), len(n.Sent()))
+}
+
+func TestQueueLen(t *testing.T) {
+	n := &fakeNotifier{delay: 300 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MsgBufSize = 5
+	tn := newTestNotifier(t, c, n)
+	require.Equal(t, 0, tn.QueueLen())
+	require.Equal(t, 5, tn.QueueCap())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The worker is blocked by the first message
+	require.Equal(t, nil, tn.SendAsync("0", "text"))
+	require.Eventually(t, func() bool {
+		return tn.QueueLen() == 0
+	}, time.Second, time.Millisecond, "the worker must take the first message")
+	for i := 1; i <= 3; i++ {
+		require.Equal(t, nil, tn.SendAsync(fmt.Sprint(i), "text"))
+		require.Equal(t, i, tn.QueueLen())
+	}
+	require.Equal(t, 5, tn.QueueCap())
+
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+	require.Equal(t, 0, tn.QueueLen())
+	require.Equal(t, 4, len(n.Sent()))
+}
+
+func TestSendDurationObserver(t *testing.T) {
+	n := &fakeNotifier{delay: 20 * time.Millisecond}
+	tn := newTestNotifier(t, newTestConfig(), n)
+
+	var mu sync.Mutex
+	var durations []time.Duration
+	tn.AddSendDurationObserver(func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		durations = append(durations, d)
+	})
+	tn.AddSendDurationObserver(nil)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	tn.UnitQuit()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 1, len(durations))
+	require.GreaterOrEqual(t, durations[0], 20*time.Millisecond)
+}
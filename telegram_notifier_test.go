@@ -1,15 +1,28 @@
 package telegram_notifier
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	_ "time/tzdata" // time zones for TestLogTimeFormatAndZone
 
 	"github.com/igulib/app"
+	"github.com/nikoksr/notify"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 // testId is used to create a new telegram_notifier unit name for each test.
@@ -92,9 +105,109 @@ func teardown(m *testing.M) {
 	}
 }
 
+// fakeNotifier implements MessageSender and records sent messages
+// instead of sending them via Telegram.
+type fakeNotifier struct {
+	mu          sync.Mutex
+	sent        []TelegramMessage
+	calls       int
+	failures    []error // returned by the first calls before err
+	err         error
+	delay       time.Duration
+	inFlight    int
+	maxInFlight int
+}
+
+func (n *fakeNotifier) Send(ctx context.Context, subject, message string) error {
+	return n.sendMessage(ctx, TelegramMessage{Title: subject, Text: message})
+}
+
+// sendMessage implements messageOptionsSender to record message options.
+func (n *fakeNotifier) sendMessage(ctx context.Context, msg TelegramMessage) error {
+	n.mu.Lock()
+	n.inFlight++
+	if n.inFlight > n.maxInFlight {
+		n.maxInFlight = n.inFlight
+	}
+	delay := n.delay
+	n.mu.Unlock()
+
+	var ctxErr error
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			ctxErr = ctx.Err()
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.inFlight--
+	n.calls++
+	if ctxErr != nil {
+		return ctxErr
+	}
+	if len(n.failures) > 0 {
+		err := n.failures[0]
+		n.failures = n.failures[1:]
+		return err
+	}
+	if n.err != nil {
+		return n.err
+	}
+	msg.ctx = nil
+	msg.enqueuedAt = time.Time{}
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func (n *fakeNotifier) Calls() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+func (n *fakeNotifier) MaxInFlight() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.maxInFlight
+}
+
+func (n *fakeNotifier) Sent() []TelegramMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]TelegramMessage(nil), n.sent...)
+}
+
+// newTestConfig returns a minimal valid config that doesn't require
+// a real Telegram bot. Rate limits are disabled to speed up tests.
+func newTestConfig() *Config {
+	return &Config{
+		BotToken:                 "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:                  []int64{1},
+		MaxMessagesPerSec:        -1,
+		MaxMessagesPerChatPerSec: -1,
+	}
+}
+
+// newTestNotifier creates a TelegramNotifier that uses the specified
+// fake sender instead of the real Telegram service.
+func newTestNotifier(t *testing.T, c *Config, n MessageSender) *TelegramNotifier {
+	tn, err := New(t.Name(), c)
+	require.Equal(t, nil, err, "telegram_notifier must be created successfully")
+	tn.SetSender(n)
+	return tn
+}
+
 // TEST SETUP END
 
 func TestBasicUsage(t *testing.T) {
+	if os.Getenv("BOT_TOKEN") == "" || os.Getenv("CHAT_IDS") == "" {
+		t.Skip("BOT_TOKEN and CHAT_IDS environment variables must be set to run this test")
+	}
 
 	configBytes, err := os.ReadFile("./test_data/TestBasicUsage.yaml")
 	require.Equal(t, nil, err)
@@ -115,9 +228,2098 @@ func TestBasicUsage(t *testing.T) {
 	err = tn.SendAsync("IGULIB Telegram Notifier Test", fmt.Sprintf("This is a test message sent from telegram_notifier_test.go/TestBasicUsage at %s. OS: %q.\n", time.Now().Format(time.RFC3339), runtime.GOOS))
 	require.Equal(t, nil, err, "message must be sent successfully")
 
+	err = tn.Send(context.Background(), "IGULIB Telegram Notifier Test", fmt.Sprintf("This is a synchronous test message sent from telegram_notifier_test.go/TestBasicUsage at %s. OS: %q.\n", time.Now().Format(time.RFC3339), runtime.GOOS))
+	require.Equal(t, nil, err, "message must be delivered successfully")
+
 	_, err = app.M.Pause(unitName)
 	require.Equal(t, nil, err, "telegram_notifier must pause successfully")
 
 	_, err = app.M.Quit(unitName)
 	require.Equal(t, nil, err, "telegram_notifier must quit successfully")
 }
+
+func TestSendNotAvailable(t *testing.T) {
+	tn, err := New("TestSendNotAvailable", newTestConfig())
+	require.Equal(t, nil, err, "telegram_notifier must be created successfully")
+
+	err = tn.Send(context.Background(), "title", "text")
+	require.ErrorIs(t, err, ErrUnitNotAvailable, "Send must fail if unit not started")
+
+	r := tn.UnitPause()
+	require.Equal(t, true, r.OK)
+
+	err = tn.Send(context.Background(), "title", "text")
+	require.ErrorIs(t, err, ErrUnitNotAvailable, "Send must fail if unit paused")
+}
+
+func TestUnitStartResumesPausedUnit(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.FailureThreshold = 1
+	c.BreakerCooldownMs = 60000
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	r = tn.UnitPause()
+	require.Equal(t, true, r.OK)
+	require.ErrorIs(t, tn.SendAsync("title", "paused"), ErrUnitNotAvailable)
+
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, app.UAvailable, tn.UnitAvailability())
+	require.Equal(t, AvailabilityReasonAvailable, tn.AvailabilityReason())
+	require.Equal(t, nil, tn.SendAsync("title", "resumed"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"resumed"}, sentTexts(n))
+
+	// The unit unavailable for another reason is not resumed
+	n.mu.Lock()
+	n.err = errors.New("Bad Request: chat not found")
+	n.mu.Unlock()
+	require.Equal(t, nil, tn.SendAsync("title", "failed"))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, AvailabilityReasonCircuitOpen, tn.AvailabilityReason())
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, app.UTemporarilyUnavailable, tn.UnitAvailability())
+	require.Equal(t, AvailabilityReasonCircuitOpen, tn.AvailabilityReason())
+
+	tn.UnitQuit()
+}
+
+func TestSendAsyncCtx(t *testing.T) {
+	tn, err := New("TestSendAsyncCtx", newTestConfig())
+	require.Equal(t, nil, err, "telegram_notifier must be created successfully")
+
+	err = tn.SendAsyncCtx(context.Background(), "title", "text")
+	require.ErrorIs(t, err, ErrUnitNotAvailable, "SendAsyncCtx must fail if unit not started")
+
+	n := &fakeNotifier{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err = tn.send(n, TelegramMessage{Title: "title", Text: "text", ctx: ctx})
+	require.Equal(t, nil, err, "message must be sent while context is active")
+	require.Equal(t, 1, len(n.Sent()))
+
+	cancel()
+	err = tn.send(n, TelegramMessage{Title: "title", Text: "text", ctx: ctx})
+	require.ErrorIs(t, err, context.Canceled, "message with cancelled context must be skipped")
+	require.Equal(t, 1, len(n.Sent()), "message with cancelled context must not be sent")
+}
+
+func TestChatIdsEnvVar(t *testing.T) {
+	t.Setenv("TEST_CHAT_IDS_ENV_VAR", "10, 20,30")
+
+	// ChatIdsEnvVar must work without BotTokenEnvVar
+	c := &Config{
+		BotToken:      "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIdsEnvVar: "TEST_CHAT_IDS_ENV_VAR",
+		ChatIds:       []int64{1},
+	}
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, []int64{10, 20, 30}, v.ChatIds, "chat ids must be taken from the environment variable")
+
+	// Explicit ChatIds must be used if the environment variable is not set
+	c.ChatIdsEnvVar = "TEST_CHAT_IDS_ENV_VAR_UNSET"
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, []int64{1}, v.ChatIds, "explicit chat ids must be used")
+
+	// A whitespace-only value is treated as unset
+	c.ChatIdsEnvVar = "TEST_CHAT_IDS_ENV_VAR"
+	t.Setenv("TEST_CHAT_IDS_ENV_VAR", " \t ")
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, []int64{1}, v.ChatIds, "explicit chat ids must be used")
+
+	// Empty items are skipped
+	t.Setenv("TEST_CHAT_IDS_ENV_VAR", "10,20, ,")
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, []int64{10, 20}, v.ChatIds)
+
+	t.Setenv("TEST_CHAT_IDS_ENV_VAR", ", ,")
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.Contains(t, err.Error(), "no chat_ids")
+
+	// Repeated chat ids are removed
+	t.Setenv("TEST_CHAT_IDS_ENV_VAR", "30,10,30,20,10")
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, []int64{30, 10, 20}, v.ChatIds)
+
+	c.ChatIdsEnvVar = ""
+	c.ChatIds = []int64{1, 2, 1}
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, []int64{1, 2}, v.ChatIds)
+	c.ChatIdsEnvVar = "TEST_CHAT_IDS_ENV_VAR"
+
+	// Non-numeric items are still rejected
+	t.Setenv("TEST_CHAT_IDS_ENV_VAR", "10,,abc")
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.Contains(t, err.Error(), `"abc"`)
+}
+
+func TestConcurrentSendAsync(t *testing.T) {
+	tn, sink := NewTestNotifier(t.Name())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK, "telegram_notifier must start successfully")
+
+	const goroutines = 20
+	const messagesPerGoroutine = 50
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < messagesPerGoroutine; j++ {
+				err := tn.SendAsync("title", fmt.Sprintf("message %d-%d", i, j))
+				require.Equal(t, nil, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	r = tn.UnitQuit()
+	require.Equal(t, true, r.OK, "telegram_notifier must quit successfully")
+	require.Equal(t, goroutines*messagesPerGoroutine, len(sink.Messages()), "all messages must be sent")
+}
+
+func TestFullBufferNoDeadlock(t *testing.T) {
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, newTestConfig(), n)
+
+	// Block telegram service initialization so that nothing drains the buffer
+	release := make(chan struct{})
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		<-release
+		return n, nil
+	}
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK, "telegram_notifier must start successfully")
+
+	// Fill the buffer
+	for i := 0; i < cap(tn.tgMsgChan); i++ {
+		err := tn.SendAsync("title", "text")
+		require.Equal(t, nil, err)
+	}
+
+	// This send blocks until the buffer is drained
+	sendDone := make(chan error)
+	go func() {
+		sendDone <- tn.SendAsync("title", "text")
+	}()
+
+	// Give the sender time to block on the full buffer
+	time.Sleep(50 * time.Millisecond)
+
+	// UnitPause must not block while a sender waits for the full buffer
+	pauseDone := make(chan app.UnitOperationResult)
+	go func() {
+		pauseDone <- tn.UnitPause()
+	}()
+	select {
+	case r = <-pauseDone:
+		require.Equal(t, true, r.OK)
+	case <-time.After(time.Second):
+		t.Fatal("UnitPause blocked while message buffer is full")
+	}
+
+	quitDone := make(chan app.UnitOperationResult)
+	go func() {
+		quitDone <- tn.UnitQuit()
+	}()
+
+	close(release)
+
+	select {
+	case err := <-sendDone:
+		require.Equal(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("SendAsync blocked after message buffer drained")
+	}
+
+	select {
+	case r = <-quitDone:
+		require.Equal(t, true, r.OK)
+	case <-time.After(time.Second):
+		t.Fatal("UnitQuit deadlocked")
+	}
+	require.Equal(t, cap(tn.tgMsgChan)+1, len(n.Sent()), "all messages must be sent")
+}
+
+func TestSendConcurrency(t *testing.T) {
+	c := newTestConfig()
+	c.SendConcurrency = 3
+	n := &fakeNotifier{delay: 5 * time.Millisecond}
+	tn := newTestNotifier(t, c, n)
+	require.Equal(t, 3, tn.cfg().SendConcurrency)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK, "telegram_notifier must start successfully")
+
+	for i := 0; i < 30; i++ {
+		err := tn.SendAsync("title", "text")
+		require.Equal(t, nil, err)
+	}
+
+	r = tn.UnitQuit()
+	require.Equal(t, true, r.OK, "telegram_notifier must quit successfully")
+	require.Equal(t, 30, len(n.Sent()), "all messages must be sent")
+	require.LessOrEqual(t, n.MaxInFlight(), 3, "number of simultaneous sends must be bounded")
+
+	c.SendConcurrency = -1
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadSendConcurrency)
+
+	c.SendConcurrency = 0
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, DefaultSendConcurrency, v.SendConcurrency)
+}
+
+func BenchmarkSendAsyncBurst(b *testing.B) {
+	const burst = 10000
+	for i := 0; i < b.N; i++ {
+		n := &fakeNotifier{}
+		tn, err := New("BenchmarkSendAsyncBurst", newTestConfig())
+		require.Equal(b, nil, err)
+		tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+			return n, nil
+		}
+		tn.UnitStart()
+
+		baseline := runtime.NumGoroutine()
+		maxGoroutines := baseline
+		for j := 0; j < burst; j++ {
+			_ = tn.SendAsync("title", "text")
+			if g := runtime.NumGoroutine(); g > maxGoroutines {
+				maxGoroutines = g
+			}
+		}
+		tn.UnitQuit()
+
+		if maxGoroutines > baseline+DefaultSendConcurrency {
+			b.Fatalf("goroutine count is not bounded: baseline %d, max %d", baseline, maxGoroutines)
+		}
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	transientErr := errors.New("Internal Server Error")
+	permanentErr := errors.New("Bad Request: chat not found")
+
+	c := newTestConfig()
+	c.MaxRetries = 2
+	c.RetryBaseDelayMs = 1
+
+	// Transient failures are retried
+	n := &fakeNotifier{failures: []error{transientErr, transientErr}}
+	tn := newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Eventually(t, func() bool { return n.Calls() == 3 }, time.Second, time.Millisecond,
+		"message must be retried twice")
+	tn.UnitQuit()
+	require.Equal(t, 1, len(n.Sent()), "message must be sent after retries")
+	require.Equal(t, uint64(0), tn.DroppedMessages())
+
+	// Message is dropped after retries are exhausted
+	n = &fakeNotifier{err: transientErr}
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Eventually(t, func() bool { return n.Calls() == 3 }, time.Second, time.Millisecond,
+		"message must be retried twice")
+	tn.UnitQuit()
+	require.Equal(t, uint64(1), tn.DroppedMessages())
+
+	// Permanent failures are not retried
+	n = &fakeNotifier{err: permanentErr}
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	tn.UnitQuit()
+	require.Equal(t, 1, n.Calls(), "permanent failure must not be retried")
+	require.Equal(t, uint64(1), tn.DroppedMessages())
+
+	// Retries stop when the unit quits
+	c.RetryBaseDelayMs = 60000
+	n = &fakeNotifier{err: transientErr}
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	tn.UnitQuit()
+	require.Less(t, time.Since(start), 5*time.Second, "UnitQuit must interrupt retries")
+	require.Equal(t, uint64(1), tn.DroppedMessages())
+
+	// Retries can be disabled
+	c.MaxRetries = -1
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, 0, v.MaxRetries)
+}
+
+func TestSendRetryAfter(t *testing.T) {
+	rateLimitErr := errors.New("Too Many Requests: retry after 1")
+
+	d, ok := retryAfter(rateLimitErr)
+	require.Equal(t, true, ok)
+	require.Equal(t, time.Second, d)
+
+	_, ok = retryAfter(errors.New("Internal Server Error"))
+	require.Equal(t, false, ok)
+
+	// The Bot API error parameters take precedence over the text
+	apiErr := &apiError{Code: 429, Description: "Too Many Requests: retry after 1", RetryAfter: 7}
+	d, ok = retryAfter(newSendError(1, apiErr))
+	require.Equal(t, true, ok)
+	require.Equal(t, 7*time.Second, d)
+	d, ok = retryAfter(&apiError{Code: 429, Description: "Too Many Requests", RetryAfter: 3})
+	require.Equal(t, true, ok)
+	require.Equal(t, 3*time.Second, d)
+
+	c := newTestConfig()
+	c.RetryBaseDelayMs = 1
+	n := &fakeNotifier{failures: []error{rateLimitErr}}
+	tn := newTestNotifier(t, c, n)
+	tn.UnitStart()
+
+	start := time.Now()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Eventually(t, func() bool { return len(n.Sent()) == 1 }, 3*time.Second, time.Millisecond,
+		"message must be sent after rate limit delay")
+	require.GreaterOrEqual(t, time.Since(start), time.Second, "retry_after delay must be honored")
+	require.Equal(t, 2, n.Calls())
+	tn.UnitQuit()
+
+	// Delay is capped by MaxRetryAfterSec
+	c.MaxRetryAfterSec = 1
+	n = &fakeNotifier{failures: []error{errors.New("Too Many Requests: retry after 3600")}}
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	require.Eventually(t, func() bool { return len(n.Sent()) == 1 }, 3*time.Second, time.Millisecond,
+		"retry_after delay must be capped")
+	tn.UnitQuit()
+
+	c.MaxRetryAfterSec = -1
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadMaxRetryAfter)
+}
+
+func TestSendSilent(t *testing.T) {
+	c := newTestConfig()
+	c.LogLevels = []string{"info", "error"}
+	c.SilentByLevel = []string{"info"}
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, c, n)
+	tn.UnitStart()
+
+	require.Equal(t, nil, tn.SendSilent("silent", "text"))
+	require.Equal(t, nil, tn.SendAsync("loud", "text"))
+	tn.ForwardLog(zerolog.InfoLevel, "info message")
+	tn.ForwardLog(zerolog.ErrorLevel, "error message")
+	tn.UnitQuit()
+
+	silent := map[string]bool{}
+	for _, m := range n.Sent() {
+		silent[m.Title+" "+m.Text] = m.silent
+	}
+	require.Equal(t, map[string]bool{
+		"silent text":         true,
+		"loud text":           false,
+		"INFO info message":   true,
+		"ERROR error message": false,
+	}, silent)
+
+	c.SilentByLevel = []string{"bad"}
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogLevel)
+}
+
+func TestSendToThread(t *testing.T) {
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, newTestConfig(), n)
+	tn.UnitStart()
+
+	require.ErrorIs(t, tn.SendToThread(10, 0, "title", "text"), ErrBadTelegramThreadId)
+	require.Equal(t, nil, tn.SendToThread(10, 3, "title", "text"))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 1, len(sent))
+	require.Equal(t, []int64{10}, sent[0].chatIds)
+	require.Equal(t, 3, sent[0].threadId)
+}
+
+func TestSendMessage(t *testing.T) {
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, newTestConfig(), n)
+	tn.UnitStart()
+
+	err := tn.SendMessage(MessageOptions{
+		Title:     "title",
+		Text:      "<b>text</b>",
+		ParseMode: ParseModeHTML,
+		Silent:    true,
+		ChatIds:   []int64{2, 3},
+		ThreadId:  4,
+	})
+	require.Equal(t, nil, err)
+
+	err = tn.SendMessage(MessageOptions{Title: "default", Text: "text"})
+	require.Equal(t, nil, err)
+
+	err = tn.SendMessage(MessageOptions{Title: "title", Text: "text", ParseMode: "bad"})
+	require.ErrorIs(t, err, ErrBadParseMode)
+
+	err = tn.SendMessage(MessageOptions{Title: "title", Text: "text", ThreadId: -1})
+	require.ErrorIs(t, err, ErrBadTelegramThreadId)
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 2, len(sent))
+	for _, m := range sent {
+		if m.Title == "default" {
+			// The configured chats and options are resolved when the message is sent
+			require.Equal(t, TelegramMessage{Title: "default", Text: "text", chatIds: []int64{1},
+				disableWebPagePreview: new(bool), protectContent: new(bool)}, m,
+				"zero-valued options must fall back to defaults")
+			continue
+		}
+		require.Equal(t, ParseModeHTML, m.parseMode)
+		require.Equal(t, true, m.silent)
+		require.Equal(t, []int64{2, 3}, m.chatIds)
+		require.Equal(t, 4, m.threadId)
+	}
+}
+
+func TestParseJsonConfig(t *testing.T) {
+	configBytes, err := os.ReadFile("./test_data/TestParseJsonConfig.json")
+	require.Equal(t, nil, err)
+
+	config, err := ParseJsonConfig(configBytes)
+	require.Equal(t, nil, err)
+	require.Equal(t, &Config{
+		BotToken:            "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:             []int64{10, -20},
+		LogLevels:           []string{"error", "fatal"},
+		LogOnlyWithPrefixes: []string{"ALERT"},
+		LogDateTime:         true,
+		ParseMode:           ParseModeHTML,
+	}, config)
+
+	_, err = validateConfig(config)
+	require.Equal(t, nil, err)
+
+	// Malformed JSON
+	_, err = ParseJsonConfig([]byte(`{"bot_token": "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",`))
+	require.NotEqual(t, nil, err)
+
+	// Missing required fields
+	config, err = ParseJsonConfig([]byte(`{"bot_token": "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789"}`))
+	require.Equal(t, nil, err)
+	_, err = validateConfig(config)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+}
+
+// failingReader returns data and then fails with err.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestParseConfigReader(t *testing.T) {
+	config, err := ParseYamlConfigReader(strings.NewReader("bot_token: \"123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789\"\nchat_ids: [1, 2]\n---\nbot_token: other\n"))
+	require.Equal(t, nil, err)
+	require.Equal(t, "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789", config.BotToken, "only the first document must be parsed")
+	require.Equal(t, []int64{1, 2}, config.ChatIds)
+
+	config, err = ParseJsonConfigReader(strings.NewReader(`{"bot_token": "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789", "chat_ids": [1, 2]}`))
+	require.Equal(t, nil, err)
+	require.Equal(t, "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789", config.BotToken)
+	require.Equal(t, []int64{1, 2}, config.ChatIds)
+
+	// Empty YAML document is allowed like with ParseYamlConfig
+	_, err = ParseYamlConfig([]byte{})
+	require.Equal(t, nil, err)
+
+	readErr := errors.New("read failed")
+	_, err = ParseYamlConfigReader(&failingReader{data: []byte("bot_token: \"123"), err: readErr})
+	require.ErrorContains(t, err, readErr.Error())
+
+	_, err = ParseJsonConfigReader(&failingReader{data: []byte(`{"bot_token": "123`), err: readErr})
+	require.ErrorIs(t, err, readErr)
+
+	// JSON must contain a single config
+	_, err = ParseJsonConfig([]byte{})
+	require.ErrorIs(t, err, ErrBadJsonConfig)
+	_, err = ParseJsonConfigReader(strings.NewReader(" \n"))
+	require.ErrorIs(t, err, ErrBadJsonConfig)
+	_, err = ParseJsonConfig([]byte(`{"chat_ids": [1]} garbage`))
+	require.ErrorIs(t, err, ErrBadJsonConfig)
+	_, err = ParseJsonConfig([]byte(`{"chat_ids": [1]}{"chat_ids": [2]}`))
+	require.ErrorIs(t, err, ErrBadJsonConfig)
+	_, err = ParseJsonConfig([]byte("{\"chat_ids\": [1]}\n"))
+	require.Equal(t, nil, err, "trailing whitespace is allowed")
+}
+
+func TestValidateConfigReportsAllErrors(t *testing.T) {
+	c := &Config{
+		LogLevels: []string{"error", "bad-level"},
+		ParseMode: "bad-mode",
+	}
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadTelegramBotToken)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.ErrorIs(t, err, ErrBadLogLevel)
+	require.ErrorIs(t, err, ErrBadParseMode)
+	require.ErrorContains(t, err, "bad-level")
+}
+
+func TestBotTokenFormat(t *testing.T) {
+	valid := []string{
+		"123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		"1:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw_-",
+		"5555555555:AAG-very_long_secret_from_a_local_bot_api_server_0123456789",
+	}
+	for _, token := range valid {
+		c := newTestConfig()
+		c.BotToken = token
+		_, err := validateConfig(c)
+		require.Equal(t, nil, err, "token must be valid: %q", token)
+	}
+
+	invalid := []string{
+		"test-token",
+		":ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		"bot123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		"123456:short",
+		"123456:ABCdefGHIjklMNOpqrSTUvwxYZ01234567 9",
+		"123456ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+	}
+	for _, token := range invalid {
+		c := newTestConfig()
+		c.BotToken = token
+		_, err := validateConfig(c)
+		require.ErrorIs(t, err, ErrBadTelegramBotToken, "token must be invalid: %q", token)
+		require.NotContains(t, err.Error(), token, "error must not contain the token")
+	}
+
+	// Token from the environment variable is validated too
+	t.Setenv("TEST_BOT_TOKEN_ENV_VAR", "invalid")
+	c := newTestConfig()
+	c.BotTokenEnvVar = "TEST_BOT_TOKEN_ENV_VAR"
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadTelegramBotToken)
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := join(dir, "bot_token")
+	chatIdsFile := join(dir, "chat_ids")
+	emptyFile := join(dir, "empty")
+	fileToken := "654321:FileTokenFileTokenFileTokenFileToken"
+	require.Equal(t, nil, os.WriteFile(tokenFile, []byte("  "+fileToken+"\n"), 0600))
+	require.Equal(t, nil, os.WriteFile(chatIdsFile, []byte("10\n20, 30\n"), 0600))
+	require.Equal(t, nil, os.WriteFile(emptyFile, []byte(" \n"), 0600))
+
+	c := newTestConfig()
+	c.BotTokenFile = tokenFile
+	c.ChatIdsFile = chatIdsFile
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, fileToken, v.BotToken, "file must have precedence over bot_token")
+	require.Equal(t, []int64{10, 20, 30}, v.ChatIds, "file must have precedence over chat_ids")
+
+	// Environment variables have precedence over files
+	envToken := "111111:EnvTokenEnvTokenEnvTokenEnvTokenEnvToken"
+	t.Setenv("TEST_CONFIG_FILES_BOT_TOKEN", envToken)
+	t.Setenv("TEST_CONFIG_FILES_CHAT_IDS", "40")
+	c.BotTokenEnvVar = "TEST_CONFIG_FILES_BOT_TOKEN"
+	c.ChatIdsEnvVar = "TEST_CONFIG_FILES_CHAT_IDS"
+	v, err = validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, envToken, v.BotToken)
+	require.Equal(t, []int64{40}, v.ChatIds)
+
+	// Missing files
+	c = newTestConfig()
+	c.BotTokenFile = join(dir, "missing")
+	c.ChatIdsFile = join(dir, "missing")
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadTelegramBotToken)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// Empty files
+	c.BotTokenFile = emptyFile
+	c.ChatIdsFile = emptyFile
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadTelegramBotToken)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.ErrorContains(t, err, "is empty")
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.Equal(t, nil, newTestConfig().Validate())
+
+	var nilConfig *Config
+	require.ErrorIs(t, nilConfig.Validate(), ErrLogTelegramConfigIsNil)
+
+	c := newTestConfig()
+	c.BotToken = ""
+	c.SendConcurrency = -1
+	err := c.Validate()
+	require.ErrorIs(t, err, ErrBadTelegramBotToken)
+	require.ErrorIs(t, err, ErrBadSendConcurrency)
+
+	_, newErr := New("TestConfigValidate", c)
+	require.Equal(t, err.Error(), newErr.Error(), "New must report the same errors")
+}
+
+func TestConfigRedactsBotToken(t *testing.T) {
+	c := newTestConfig()
+	c.ChatIds = []int64{12345}
+	c.LogLevels = []string{"error"}
+	secret := "ABCdefGHIjklMNOpqrSTUvwxYZ012345"
+
+	jsonBytes, err := json.Marshal(c)
+	require.Equal(t, nil, err)
+	yamlBytes, err := yaml.Marshal(c)
+	require.Equal(t, nil, err)
+
+	for _, out := range []string{
+		string(jsonBytes),
+		string(yamlBytes),
+		c.String(),
+		fmt.Sprintf("%v", c),
+		fmt.Sprintf("%+v", *c),
+		fmt.Sprintf("%#v", c),
+	} {
+		require.NotContains(t, out, secret, "bot token must be redacted")
+		require.Contains(t, out, redactedMarker+"6789", "last 4 characters must be kept")
+		require.Contains(t, out, "12345", "chat ids must be present")
+		require.Contains(t, out, "error", "log levels must be present")
+	}
+
+	// Real value is still used internally
+	v, err := validateConfig(c)
+	require.Equal(t, nil, err)
+	require.Equal(t, "123456:"+secret+"6789", v.BotToken)
+
+	require.Equal(t, redactedMarker, redactBotToken("short"))
+	require.Equal(t, "", redactBotToken(""))
+}
+
+func TestSendTimeoutPerUnit(t *testing.T) {
+	n := &fakeNotifier{delay: 10 * time.Second}
+	c := newTestConfig()
+	c.SendTimeoutSec = 1
+	tn := newTestNotifier(t, c, n)
+	require.Equal(t, time.Second, tn.cfg().SendTimeout)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	defer tn.UnitQuit()
+
+	start := time.Now()
+	err := tn.Send(context.Background(), "title", "text")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "slow send must be cancelled")
+	require.Less(t, time.Since(start), 5*time.Second, "the unit timeout must be used")
+
+	// Other units keep the default
+	other, err := New(t.Name()+"_default", newTestConfig())
+	require.Equal(t, nil, err)
+	require.Equal(t, time.Duration(DefaultSendTimeoutSec)*time.Second, other.cfg().SendTimeout)
+
+	c = newTestConfig()
+	c.SendTimeoutSec = -1
+	require.ErrorIs(t, c.Validate(), ErrBadSendTimeout)
+}
+
+func TestMsgBufSizePerUnit(t *testing.T) {
+	c := newTestConfig()
+	c.MsgBufSize = 3
+	small, err := New(t.Name()+"_small", c)
+	require.Equal(t, nil, err)
+
+	c = newTestConfig()
+	c.MsgBufSize = 500
+	large, err := New(t.Name()+"_large", c)
+	require.Equal(t, nil, err)
+
+	def, err := New(t.Name()+"_default", newTestConfig())
+	require.Equal(t, nil, err)
+
+	require.Equal(t, 3, cap(small.tgMsgChan))
+	require.Equal(t, 500, cap(large.tgMsgChan))
+	require.Equal(t, DefaultMsgBufSize, cap(def.tgMsgChan))
+
+	c = newTestConfig()
+	c.MsgBufSize = -1
+	require.ErrorIs(t, c.Validate(), ErrBadMsgBufSize)
+}
+
+// fillMsgBuffer starts the unit with a single worker blocked by a slow send
+// and fills its message buffer of size 2 with messages "2" and "3".
+func fillMsgBuffer(t *testing.T, policy string) (*TelegramNotifier, *fakeNotifier) {
+	n := &fakeNotifier{delay: 300 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MsgBufSize = 2
+	c.OverflowPolicy = policy
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Eventually(t, func() bool {
+		return len(tn.tgMsgChan) == 0
+	}, time.Second, time.Millisecond, "the worker must take the first message")
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+	require.Equal(t, nil, tn.SendAsync("3", "text"))
+	require.Equal(t, 2, len(tn.tgMsgChan))
+	return tn, n
+}
+
+func sentTitles(n *fakeNotifier) []string {
+	var titles []string
+	for _, m := range n.Sent() {
+		titles = append(titles, m.Title)
+	}
+	return titles
+}
+
+func sentTexts(n *fakeNotifier) []string {
+	var texts []string
+	for _, m := range n.Sent() {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+func TestSendUrgent(t *testing.T) {
+	tn, n := fillMsgBuffer(t, OverflowPolicyBlock)
+	tn.ForwardLog(zerolog.InfoLevel, "not forwarded")
+
+	// Urgent messages jump ahead of the full buffer
+	require.Equal(t, nil, tn.SendUrgent("urgent", "text"))
+	tn.updateConfig(func(c *validatedConfig) {
+		c.LogLevels = []zerolog.Level{zerolog.FatalLevel}
+	})
+	tn.ForwardLog(zerolog.FatalLevel, "fatal")
+	require.Equal(t, 4, tn.Stats().Queued)
+
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+	require.Equal(t, []string{"1", "urgent", "FATAL", "2", "3"}, sentTitles(n))
+}
+
+func TestSendUrgentOverflow(t *testing.T) {
+	n := &fakeNotifier{delay: 200 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.UrgentBufSize = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Eventually(t, func() bool {
+		return len(tn.tgMsgChan) == 0
+	}, time.Second, time.Millisecond, "the worker must take the first message")
+
+	// The urgent message not fitting into the urgent buffer is queued
+	require.Equal(t, nil, tn.SendUrgent("urgent 1", "text"))
+	require.Equal(t, nil, tn.SendUrgent("urgent 2", "text"))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+	require.Equal(t, []string{"1", "urgent 1", "urgent 2", "2"}, sentTitles(n))
+
+	c.UrgentBufSize = -1
+	require.ErrorIs(t, c.Validate(), ErrBadUrgentBufSize)
+}
+
+func TestMaxMessageAge(t *testing.T) {
+	n := &fakeNotifier{delay: 300 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MaxMessageAgeSec = 60
+	tn := newTestNotifier(t, c, n)
+	clock := &fakeClock{now: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)}
+	tn.setClock(clock)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Eventually(t, func() bool {
+		return len(tn.tgMsgChan) == 0
+	}, time.Second, time.Millisecond, "the worker must take the first message")
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+	require.Equal(t, nil, tn.SendAsync("3", "text"))
+	// Only the messages older than the limit are dropped
+	clock.Set(clock.Now().Add(61 * time.Second))
+	require.Equal(t, nil, tn.SendAsync("4", "text"))
+	clock.Set(clock.Now().Add(59 * time.Second))
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, []string{"1", "4"}, sentTitles(n))
+	require.Equal(t, uint64(2), tn.Stats().Dropped)
+
+	tn.UnitQuit()
+
+	c.MaxMessageAgeSec = -1
+	require.ErrorIs(t, c.Validate(), ErrBadMaxMessageAge)
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	t.Run("block", func(t *testing.T) {
+		tn, n := fillMsgBuffer(t, "")
+		require.Equal(t, OverflowPolicyBlock, tn.cfg().OverflowPolicy, "block must be the default")
+
+		done := make(chan error)
+		go func() {
+			done <- tn.SendAsync("4", "text")
+		}()
+		select {
+		case <-done:
+			t.Fatal("SendAsync must block while the buffer is full")
+		case <-time.After(100 * time.Millisecond):
+		}
+		require.Equal(t, nil, <-done)
+
+		require.Equal(t, nil, tn.Flush(context.Background()))
+		tn.UnitQuit()
+		require.Equal(t, []string{"1", "2", "3", "4"}, sentTitles(n))
+		require.Equal(t, uint64(0), tn.DroppedMessages())
+	})
+
+	t.Run("drop_newest", func(t *testing.T) {
+		tn, n := fillMsgBuffer(t, OverflowPolicyDropNewest)
+
+		require.ErrorIs(t, tn.SendAsync("4", "text"), ErrMsgBufferFull)
+		require.Equal(t, uint64(1), tn.DroppedMessages())
+
+		require.Equal(t, nil, tn.Flush(context.Background()))
+		tn.UnitQuit()
+		require.Equal(t, []string{"1", "2", "3"}, sentTitles(n))
+	})
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		tn, n := fillMsgBuffer(t, OverflowPolicyDropOldest)
+
+		require.Equal(t, nil, tn.SendAsync("4", "text"))
+		require.Equal(t, uint64(1), tn.DroppedMessages())
+
+		require.Equal(t, nil, tn.Flush(context.Background()))
+		tn.UnitQuit()
+		require.Equal(t, []string{"1", "3", "4"}, sentTitles(n))
+	})
+
+	c := newTestConfig()
+	c.OverflowPolicy = "drop_all"
+	require.ErrorIs(t, c.Validate(), ErrBadOverflowPolicy)
+}
+
+func TestSendCallbacks(t *testing.T) {
+	sendErr := errors.New("Bad Request: chat not found")
+	n := &fakeNotifier{failures: []error{sendErr}}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	var mu sync.Mutex
+	var failed, succeeded []string
+	var gotErr error
+	tn.SetOnSendError(func(msg TelegramMessage, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, msg.Title)
+		gotErr = err
+	})
+	tn.SetOnSendSuccess(func(msg TelegramMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		succeeded = append(succeeded, msg.Title)
+	})
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+	tn.UnitQuit()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"1"}, failed)
+	require.ErrorIs(t, gotErr, sendErr)
+	require.Equal(t, []string{"2"}, succeeded)
+
+	// Nil callbacks are ignored
+	tn.SetOnSendError(nil)
+	tn.SetOnSendSuccess(nil)
+}
+
+func TestInternalLogger(t *testing.T) {
+	sendErr := errors.New("Bad Request: chat not found")
+	n := &fakeNotifier{err: sendErr}
+	tn := newTestNotifier(t, newTestConfig(), n)
+
+	var buf bytes.Buffer
+	var bufLock sync.Mutex
+	tn.SetInternalLogger(zerolog.New(&lockedWriter{w: &buf, mu: &bufLock}))
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	tn.UnitQuit()
+
+	bufLock.Lock()
+	defer bufLock.Unlock()
+	out := buf.String()
+	require.Contains(t, out, "failed to send message")
+	require.Contains(t, out, sendErr.Error(), "the error must be logged")
+	require.Contains(t, out, t.Name(), "the unit name must be logged")
+}
+
+func TestIsLogForwardingEnabled(t *testing.T) {
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, newTestConfig(), n)
+	var buf bytes.Buffer
+	var bufLock sync.Mutex
+	tn.SetInternalLogger(zerolog.New(&lockedWriter{w: &buf, mu: &bufLock}))
+	require.Equal(t, false, tn.IsLogForwardingEnabled())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	tn.ForwardLog(zerolog.ErrorLevel, "not forwarded")
+	require.Equal(t, false, tn.AcceptsLogLevel(zerolog.ErrorLevel))
+
+	require.Equal(t, nil, tn.SetLogLevels([]string{"error"}))
+	require.Equal(t, true, tn.IsLogForwardingEnabled())
+	tn.ForwardLog(zerolog.ErrorLevel, "forwarded")
+	tn.UnitQuit()
+	require.Equal(t, 1, len(n.Sent()))
+
+	// The warning is logged once
+	bufLock.Lock()
+	defer bufLock.Unlock()
+	require.Equal(t, 1, strings.Count(buf.String(), "log_levels is empty"))
+}
+
+func TestName(t *testing.T) {
+	tn, err := New("alerts", newTestConfig())
+	require.Equal(t, nil, err)
+	require.Equal(t, "alerts", tn.Name())
+}
+
+// lockedWriter allows reading the buffer written by other goroutines.
+type lockedWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// panicNotifier panics on the first send.
+type panicNotifier struct {
+	fakeNotifier
+	panicked atomic.Bool
+}
+
+func (n *panicNotifier) sendMessage(ctx context.Context, msg TelegramMessage) error {
+	if n.panicked.CompareAndSwap(false, true) {
+		panic("bad message")
+	}
+	return n.fakeNotifier.sendMessage(ctx, msg)
+}
+
+func TestSendPanicRecovered(t *testing.T) {
+	n := &panicNotifier{}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+	var buf bytes.Buffer
+	var bufLock sync.Mutex
+	tn.SetInternalLogger(zerolog.New(&lockedWriter{w: &buf, mu: &bufLock}))
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+
+	quitDone := make(chan struct{})
+	go func() {
+		tn.UnitQuit()
+		close(quitDone)
+	}()
+	select {
+	case <-quitDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("UnitQuit must return after a send panicked")
+	}
+
+	// The worker survives the panic
+	require.Equal(t, 1, len(n.Sent()))
+	require.Equal(t, uint64(1), tn.Stats().Failed)
+	bufLock.Lock()
+	defer bufLock.Unlock()
+	require.Contains(t, buf.String(), "bad message")
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	n := &fakeNotifier{delay: 30 * time.Second}
+	c := newTestConfig()
+	c.SendTimeoutSec = 60
+	c.ShutdownTimeoutSec = 1
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+	tn.SetInternalLogger(zerolog.Nop())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+
+	start := time.Now()
+	r = tn.UnitQuit()
+	elapsed := time.Since(start)
+	require.Equal(t, true, r.OK)
+	require.ErrorIs(t, r.CollateralError, ErrShutdownTimeout, "partial drain must be reported")
+	require.GreaterOrEqual(t, elapsed, time.Second)
+	require.Less(t, elapsed, 3*time.Second, "quit must return within the shutdown timeout")
+	require.Equal(t, 0, len(n.Sent()))
+	require.Equal(t, uint64(2), tn.Stats().Failed)
+
+	// Quit without pending messages doesn't report timeout
+	n = &fakeNotifier{}
+	tn = newTestNotifier(t, newTestConfig(), n)
+	require.Equal(t, time.Duration(DefaultShutdownTimeoutSec)*time.Second, tn.cfg().ShutdownTimeout)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	r = tn.UnitQuit()
+	require.Equal(t, nil, r.CollateralError)
+	require.Equal(t, 1, len(n.Sent()))
+
+	c = newTestConfig()
+	c.ShutdownTimeoutSec = -1
+	require.ErrorIs(t, c.Validate(), ErrBadShutdownTimeout)
+}
+
+// ctxIgnoringSender blocks each send until it is released
+// regardless of the context.
+type ctxIgnoringSender struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *ctxIgnoringSender) Send(ctx context.Context, subject, message string) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func TestShutdownAbandonsStuckSends(t *testing.T) {
+	s := &ctxIgnoringSender{started: make(chan struct{}, 2), release: make(chan struct{})}
+	c := newTestConfig()
+	c.ShutdownTimeoutSec = 1
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, s)
+	tn.SetInternalLogger(zerolog.Nop())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	<-s.started
+
+	start := time.Now()
+	r = tn.UnitQuit()
+	require.Less(t, time.Since(start), 4*time.Second, "quit must not wait for the stuck send")
+	require.ErrorIs(t, r.CollateralError, ErrShutdownTimeout)
+	require.ErrorContains(t, r.CollateralError, "1 requests still pending")
+
+	// The unit can't be started while the abandoned send is stuck
+	start = time.Now()
+	r = tn.UnitStart()
+	require.Less(t, time.Since(start), 3*time.Second, "start must not wait for the stuck send")
+	require.Equal(t, false, r.OK)
+	require.ErrorIs(t, r.CollateralError, ErrAbandonedSendsRunning)
+	require.Equal(t, app.UNotAvailable, tn.UnitAvailability())
+
+	// The unit is restarted after the abandoned send returns
+	close(s.release)
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.Send(context.Background(), "title", "text"))
+	r = tn.UnitQuit()
+	require.Equal(t, nil, r.CollateralError)
+}
+
+// ctxRecordingSender blocks each send until its context is done
+// and records the context error.
+type ctxRecordingSender struct {
+	started chan struct{}
+	errs    chan error
+}
+
+func (s *ctxRecordingSender) Send(ctx context.Context, subject, message string) error {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	s.errs <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestQuitCancelsSends(t *testing.T) {
+	s := &ctxRecordingSender{started: make(chan struct{}, 1), errs: make(chan error, 1)}
+	c := newTestConfig()
+	c.SendTimeoutSec = 60
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, s)
+	tn.SetInternalLogger(zerolog.Nop())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	<-s.started
+
+	// The ongoing send is cancelled as soon as UnitQuit is called,
+	// not after the default shutdown timeout
+	start := time.Now()
+	r = tn.UnitQuit()
+	require.Less(t, time.Since(start), 2*time.Second, "quit must not wait for the shutdown timeout")
+	require.Equal(t, nil, r.CollateralError)
+	select {
+	case err := <-s.errs:
+		require.ErrorIs(t, err, context.Canceled, "the send must be cancelled, not timed out")
+	default:
+		t.Fatal("the send context must be done when UnitQuit returns")
+	}
+	require.Equal(t, uint64(1), tn.Stats().Failed)
+}
+
+func TestQuitCancelsInit(t *testing.T) {
+	c := newTestConfig()
+	c.SendTimeoutSec = 60
+	c.ShutdownTimeoutSec = 1
+	tn := newTestNotifier(t, c, nil)
+	tn.SetInternalLogger(zerolog.Nop())
+
+	initStarted := make(chan struct{}, 1)
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		initStarted <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	// Nothing is pending, the initialization is cancelled immediately
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	<-initStarted
+	start := time.Now()
+	r = tn.UnitQuit()
+	require.Less(t, time.Since(start), 500*time.Millisecond, "quit must not wait for the initialization")
+	require.Equal(t, nil, r.CollateralError)
+
+	// The pending message may be delivered until the shutdown timeout
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	<-initStarted
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	start = time.Now()
+	r = tn.UnitQuit()
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+	require.Less(t, time.Since(start), 3*time.Second, "quit must not wait for the send timeout")
+	require.ErrorIs(t, r.CollateralError, ErrShutdownTimeout)
+}
+
+func TestFlush(t *testing.T) {
+	n := &fakeNotifier{delay: 20 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 2
+	tn := newTestNotifier(t, c, n)
+
+	// Nothing to flush
+	require.Equal(t, nil, tn.Flush(context.Background()))
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	defer tn.UnitQuit()
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		require.Equal(t, nil, tn.SendAsync("title", "text"))
+	}
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	require.Equal(t, total, len(n.Sent()), "all messages must be sent before Flush returns")
+	require.Equal(t, 0, tn.Stats().Queued)
+
+	// The unit remains available
+	require.Equal(t, app.UAvailable, tn.UnitAvailability())
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+
+	// Flush respects the context
+	n.mu.Lock()
+	n.delay = time.Second
+	n.mu.Unlock()
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, tn.Flush(ctx), context.DeadlineExceeded)
+}
+
+func TestSendToChats(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2, 3}
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.SendToChats([]int64{2, 3}, "title", "text"))
+
+	err := tn.SendToChats([]int64{2, 4, 5}, "title", "text")
+	require.ErrorIs(t, err, ErrBadTelegramChatId, "unlisted chats must be rejected")
+	require.ErrorContains(t, err, "4")
+	require.ErrorContains(t, err, "5")
+	require.ErrorIs(t, tn.SendToChats(nil, "title", "text"), ErrBadTelegramChatId)
+
+	tn.UnitQuit()
+	sent := n.Sent()
+	require.Equal(t, 1, len(sent))
+	require.Equal(t, []int64{2, 3}, sent[0].chatIds)
+
+	// Unlisted chats can be allowed
+	n = &fakeNotifier{}
+	c.AllowUnlistedChats = true
+	tn = newTestNotifier(t, c, n)
+	tn.UnitStart()
+	require.Equal(t, nil, tn.SendToChats([]int64{4}, "title", "text"))
+	tn.UnitQuit()
+	require.Equal(t, []int64{4}, n.Sent()[0].chatIds)
+}
+
+func TestAddRemoveChatId(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.ChatIds = []int64{1, 2}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.Send(context.Background(), "1", "text"))
+	tn.AddChatId(3)
+	tn.AddChatId(3)
+	require.Equal(t, nil, tn.Send(context.Background(), "2", "text"))
+	tn.RemoveChatId(1)
+	require.Equal(t, nil, tn.SendAsync("3", "text"))
+	require.Equal(t, nil, tn.SendToChats([]int64{3}, "4", "text"))
+	require.ErrorIs(t, tn.SendToChats([]int64{1}, "5", "text"), ErrBadTelegramChatId,
+		"removed chat must not be accepted")
+
+	// Concurrent modifications are safe
+	var wg sync.WaitGroup
+	for i := int64(10); i < 20; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			tn.AddChatId(id)
+			require.Equal(t, nil, tn.SendAsync("concurrent", "text"))
+			tn.RemoveChatId(id)
+		}(i)
+	}
+	wg.Wait()
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, []int64{1, 2}, sent[0].chatIds)
+	require.Equal(t, []int64{1, 2, 3}, sent[1].chatIds)
+	require.Equal(t, []int64{2, 3}, sent[2].chatIds)
+	require.Equal(t, []int64{3}, sent[3].chatIds)
+	require.Equal(t, []int64{2, 3}, tn.cfg().ChatIds)
+}
+
+func TestLogLevelRanges(t *testing.T) {
+	testCases := []struct {
+		levels   []string
+		expected []zerolog.Level
+	}{
+		{[]string{">=warning"}, []zerolog.Level{zerolog.WarnLevel, zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel}},
+		{[]string{"> error"}, []zerolog.Level{zerolog.FatalLevel, zerolog.PanicLevel}},
+		{[]string{"<=debug", "error"}, []zerolog.Level{zerolog.TraceLevel, zerolog.DebugLevel, zerolog.ErrorLevel}},
+		{[]string{"<info", ">=Fatal"}, []zerolog.Level{zerolog.TraceLevel, zerolog.DebugLevel, zerolog.FatalLevel, zerolog.PanicLevel}},
+		{[]string{"error", ">=warning"}, []zerolog.Level{zerolog.ErrorLevel, zerolog.WarnLevel, zerolog.FatalLevel, zerolog.PanicLevel}},
+		{[]string{"ALL"}, []zerolog.Level{zerolog.TraceLevel, zerolog.DebugLevel, zerolog.InfoLevel, zerolog.WarnLevel, zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel}},
+	}
+	for _, tc := range testCases {
+		levels, err := parseLogLevels(tc.levels)
+		require.Equal(t, nil, err, tc.levels)
+		require.Equal(t, tc.expected, levels, tc.levels)
+	}
+
+	for _, bad := range []string{">=", ">=bad", "=>error", ">>error", ">=disabled", "<all"} {
+		_, err := parseLogLevels([]string{bad})
+		require.ErrorIs(t, err, ErrBadLogLevel, bad)
+	}
+
+	// Ranges are applied by the hook
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{">=warning"}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Info().Msg("info")
+	logger.Warn().Msg("warning")
+	logger.Error().Msg("error")
+
+	tn.UnitQuit()
+
+	require.Equal(t, []string{"WARNING", "ERROR"}, sentTitles(n))
+}
+
+func TestRunNilEvent(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"all"}
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	discard := zerolog.New(io.Discard)
+	require.NotPanics(t, func() {
+		tn.Run(nil, zerolog.InfoLevel, "msg")
+		tn.Run(discard.Info(), zerolog.Disabled, "msg")
+	})
+	// Events of disabled loggers are nil
+	logger := zerolog.New(io.Discard).Level(zerolog.Disabled).Hook(tn)
+	logger.Error().Msg("msg")
+
+	tn.UnitQuit()
+	require.Equal(t, 0, len(n.Sent()))
+}
+
+func TestLevelTitles(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"all"}
+	c.LevelTitles = map[string]string{
+		"error":     "🔥 ERROR",
+		" Warning ": "Предупреждение",
+	}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+	tn.SetLogMessageTitleSuffix("my-app")
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("error")
+	logger.Warn().Msg("warning")
+	logger.Info().Msg("info")
+
+	tn.UnitQuit()
+
+	require.Equal(t, []string{"🔥 ERROR | my-app", "Предупреждение | my-app", "INFO | my-app"}, sentTitles(n))
+
+	// Unknown levels are reported
+	c = newTestConfig()
+	c.LevelTitles = map[string]string{"critical": "CRITICAL", "disabled": "-"}
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogLevel)
+	require.Contains(t, err.Error(), `"critical"`)
+	require.Contains(t, err.Error(), `"disabled"`)
+}
+
+func TestLevelChatRouting(t *testing.T) {
+	const generalChat, onCallChat = int64(1), int64(2)
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.ChatIds = []int64{generalChat, onCallChat}
+	c.LogLevels = []string{"all"}
+	c.LevelChatRouting = map[string][]int64{
+		"error":   {onCallChat},
+		" Fatal ": {onCallChat},
+		"info":    {generalChat},
+	}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("error")
+	logger.Info().Msg("info")
+	logger.Warn().Msg("warning")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 3, len(sent))
+	require.Equal(t, "ERROR", sent[0].Title)
+	require.Equal(t, []int64{onCallChat}, sent[0].chatIds)
+	require.Equal(t, "INFO", sent[1].Title)
+	require.Equal(t, []int64{generalChat}, sent[1].chatIds)
+	// Levels not listed are sent to all chats
+	require.Equal(t, "WARNING", sent[2].Title)
+	require.Equal(t, []int64{generalChat, onCallChat}, sent[2].chatIds)
+
+	// Unknown levels and chats are reported
+	c = newTestConfig()
+	c.LevelChatRouting = map[string][]int64{"critical": {1}, "error": {3}, "info": nil}
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogLevel)
+	require.ErrorIs(t, err, ErrBadTelegramChatId)
+	require.Contains(t, err.Error(), `"critical"`)
+	require.Contains(t, err.Error(), "chat ID 3 not listed")
+	require.Contains(t, err.Error(), `no chat IDs for level "info"`)
+
+	// Unlisted chats are allowed if configured
+	c = newTestConfig()
+	c.AllowUnlistedChats = true
+	c.LevelChatRouting = map[string][]int64{"error": {3}}
+	_, err = validateConfig(c)
+	require.Equal(t, nil, err)
+}
+
+func TestLogTimeFormatAndZone(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.LogDateTime = true
+	c.LogTimeFormat = "2006-01-02 15:04 MST"
+	c.LogTimeZone = "Asia/Tokyo"
+	c.LogUseUTC = true // ignored
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("zone")
+
+	c.LogTimeZone = ""
+	c.LogTimeFormat = "15:04 MST"
+	require.Equal(t, nil, tn.Reconfigure(c))
+	logger.Error().Msg("utc")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 2, len(sent))
+	require.Regexp(t, `^zone \| \d{4}-\d{2}-\d{2} \d{2}:\d{2} JST$`, sent[0].Text)
+	require.Regexp(t, `^utc \| \d{2}:\d{2} UTC$`, sent[1].Text)
+
+	// Invalid format and zone are reported
+	c = newTestConfig()
+	c.LogTimeFormat = " "
+	c.LogTimeZone = "Mars/Olympus_Mons"
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogTimeFormat)
+	require.ErrorIs(t, err, ErrBadLogTimeZone)
+}
+
+func TestLogTimePosition(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.LogDateTime = true
+	c.LogUseUTC = true
+	c.LogTimeFormat = "15:04:05"
+	c.LogTimePosition = LogTimePositionPrefix
+	c.MaxMessageLength = 60
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+	tn.SetLogMessageTitleSuffix("app")
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("prefix")
+	// The time is kept in the first part of a long message
+	logger.Error().Msg(strings.Repeat("long ", 10))
+
+	c.LogTimePosition = LogTimePositionSuffix
+	c.LogTimeSeparator = " @ "
+	require.Equal(t, nil, tn.Reconfigure(c))
+	logger.Error().Msg("suffix")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 4, len(sent))
+	require.Regexp(t, `^\d{2}:\d{2}:\d{2} \| prefix$`, sent[0].Text)
+	require.Equal(t, "ERROR | app", sent[0].Title)
+	require.Regexp(t, `^\d{2}:\d{2}:\d{2} \| long`, sent[1].Text)
+	require.Equal(t, "ERROR | app (part 1/2)", sent[1].Title)
+	require.Regexp(t, `^suffix @ \d{2}:\d{2}:\d{2}$`, sent[3].Text)
+
+	c.LogTimePosition = "middle"
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogTimePosition)
+}
+
+func TestIncludeHostnameAndPID(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.Equal(t, nil, err)
+	hostPart := "host=" + hostname
+	pidPart := fmt.Sprintf("pid=%d", os.Getpid())
+
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("disabled")
+
+	c.IncludeHostname = true
+	c.IncludePID = true
+	require.Equal(t, nil, tn.Reconfigure(c))
+	logger.Error().Msg("enabled")
+
+	c.LogDateTime = true
+	c.LogTimeFormat = "15:04"
+	c.LogTimePosition = LogTimePositionPrefix
+	c.IncludePID = false
+	require.Equal(t, nil, tn.Reconfigure(c))
+	logger.Error().Msg("prefix")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 3, len(sent))
+	require.Equal(t, "disabled", sent[0].Text)
+	require.Equal(t, "enabled | "+hostPart+" "+pidPart, sent[1].Text)
+	require.Regexp(t, `^\d{2}:\d{2} `+regexp.QuoteMeta(hostPart+" | prefix")+`$`, sent[2].Text)
+}
+
+func TestIncludeCaller(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"all"}
+	c.IncludeCallerForLevels = []string{">=error"}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	_, _, line, _ := runtime.Caller(0)
+	logger.Error().Msg("error")
+	logger.Warn().Msg("warning")
+
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 2, len(sent))
+	require.Equal(t, fmt.Sprintf("error | caller=telegram_notifier_test.go:%d", line+1), sent[0].Text)
+	require.Equal(t, "warning", sent[1].Text)
+
+	c.IncludeCallerForLevels = []string{"bad"}
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogLevel)
+}
+
+func TestMessageTemplate(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.Equal(t, nil, err)
+
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"all"}
+	c.LogTimeZone = "Asia/Tokyo"
+	c.IncludePID = true // ignored
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	logger := zerolog.New(io.Discard).Hook(tn)
+
+	templates := []string{
+		"{{.Level}}: {{.Message}}",
+		"[{{.Title}}] {{.Message}} on {{.Hostname}} pid={{.PID}}",
+		`{{.Message}} at {{.Time.Format "MST"}}`,
+		`{{if eq .Level "warn"}}⚠️ {{end}}{{.Message}}`,
+		// Fails to execute, the default format is used
+		"{{.Missing}}",
+	}
+	for _, tmpl := range templates {
+		c.MessageTemplate = tmpl
+		require.Equal(t, nil, tn.Reconfigure(c))
+		logger.Warn().Msg("disk full")
+	}
+	tn.UnitQuit()
+
+	require.Equal(t, []string{
+		"warn: disk full",
+		fmt.Sprintf("[WARNING] disk full on %s pid=%d", hostname, os.Getpid()),
+		"disk full at JST",
+		"⚠️ disk full",
+		fmt.Sprintf("disk full | pid=%d", os.Getpid()),
+	}, sentTexts(n))
+
+	c.MessageTemplate = "{{.Message"
+	_, err = validateConfig(c)
+	require.ErrorIs(t, err, ErrBadMessageTemplate)
+}
+
+func TestSetLogLevelsAndPrefixes(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("error 1")
+	logger.Warn().Msg("warning 1")
+
+	require.Equal(t, nil, tn.SetLogLevels([]string{"warning"}))
+	logger.Error().Msg("error 2")
+	logger.Warn().Msg("warning 2")
+
+	err := tn.SetLogLevels([]string{"error", "bad"})
+	require.ErrorIs(t, err, ErrBadLogLevel)
+	logger.Warn().Msg("warning 3")
+
+	tn.SetLogPrefixes([]string{"[tg]"})
+	logger.Warn().Msg("warning 4")
+	logger.Warn().Msg("[tg] warning 5")
+
+	tn.SetLogPrefixes(nil)
+	logger.Warn().Msg("warning 6")
+
+	// Concurrent updates are safe
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = tn.SetLogLevels([]string{"warning"})
+			tn.SetLogPrefixes([]string{"[concurrent]"})
+		}()
+		go func() {
+			defer wg.Done()
+			logger.Info().Msg("info")
+		}()
+	}
+	wg.Wait()
+
+	tn.UnitQuit()
+
+	var texts []string
+	for _, m := range n.Sent() {
+		texts = append(texts, m.Text)
+	}
+	require.Equal(t, []string{"error 1", "warning 2", "warning 3", "[tg] warning 5", "warning 6"}, texts)
+}
+
+func TestStripMatchedPrefix(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.LogOnlyWithPrefixes = []string{"ALERT:", "[tg]"}
+	c.StripMatchedPrefix = true
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Hook(tn)
+	logger.Error().Msg("ALERT: disk full")
+	logger.Error().Msg("[tg]\t [tg] nested prefix")
+	logger.Error().Msg("ALERT:")
+	logger.Error().Msg("no prefix")
+
+	tn.UnitQuit()
+
+	var texts []string
+	for _, m := range n.Sent() {
+		require.Equal(t, "ERROR", m.Title)
+		texts = append(texts, m.Text)
+	}
+	require.Equal(t, []string{"disk full", "[tg] nested prefix", ""}, texts)
+
+	// Other consumers get the original message
+	require.Contains(t, buf.String(), `"message":"ALERT: disk full"`)
+}
+
+func TestLogMatchRegexps(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.LogLevels = []string{"error"}
+	c.LogOnlyWithPrefixes = []string{"[tg]"}
+	c.LogMatchRegexps = []string{`payment.*failed`, `^disk \d+% full$`}
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	logger := zerolog.New(io.Discard).Hook(tn)
+	logger.Error().Msg("[tg] prefixed")
+	logger.Error().Msg("order 42: payment has failed")
+	logger.Error().Msg("payment succeeded")
+	logger.Error().Msg("disk 95% full")
+	logger.Error().Msg("disk 95% full again")
+
+	tn.UnitQuit()
+
+	var texts []string
+	for _, m := range n.Sent() {
+		texts = append(texts, m.Text)
+	}
+	require.Equal(t, []string{"[tg] prefixed", "order 42: payment has failed", "disk 95% full"}, texts)
+
+	// Invalid expressions are reported
+	c = newTestConfig()
+	c.LogMatchRegexps = []string{`valid`, `(unclosed`, `[bad`}
+	_, err := validateConfig(c)
+	require.ErrorIs(t, err, ErrBadLogRegexp)
+	require.Contains(t, err.Error(), "(unclosed")
+	require.Contains(t, err.Error(), "[bad")
+}
+
+func TestReconfigure(t *testing.T) {
+	const tokenB = "654321:ZYXwvuTSRqpoNMLkjiHGFedcBA9876543210"
+	notifiers := map[string]*fakeNotifier{
+		newTestConfig().BotToken: {delay: 200 * time.Millisecond},
+		tokenB:                   {},
+	}
+	c := newTestConfig()
+	tn := newTestNotifier(t, c, nil)
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		n, ok := notifiers[c.BotToken]
+		if !ok {
+			return nil, errors.New("Unauthorized")
+		}
+		return n, nil
+	}
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The message being sent during reconfiguration is not lost
+	require.Equal(t, nil, tn.SendAsync("1", "text"))
+	require.Eventually(t, func() bool {
+		return len(tn.tgMsgChan) == 0
+	}, time.Second, time.Millisecond)
+
+	newConfig := newTestConfig()
+	newConfig.BotToken = tokenB
+	newConfig.ChatIds = []int64{5}
+	newConfig.ParseMode = ParseModeHTML
+	newConfig.LogLevels = []string{"error"}
+	require.Equal(t, nil, tn.Reconfigure(newConfig))
+	require.Equal(t, nil, tn.SendAsync("2", "text"))
+
+	// Invalid config is not applied
+	badConfig := newTestConfig()
+	badConfig.ChatIds = nil
+	require.ErrorIs(t, tn.Reconfigure(badConfig), ErrBadTelegramChatId)
+
+	// Config is not applied if the new service can't be initialized
+	badConfig = newTestConfig()
+	badConfig.BotToken = "111111:UnknownUnknownUnknownUnknownUnknown"
+	require.ErrorContains(t, tn.Reconfigure(badConfig), "Unauthorized")
+	require.Equal(t, tokenB, tn.cfg().BotToken)
+
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	sentA := notifiers[newTestConfig().BotToken].Sent()
+	require.Equal(t, 1, len(sentA))
+	require.Equal(t, "1", sentA[0].Title)
+
+	sentB := notifiers[tokenB].Sent()
+	require.Equal(t, 1, len(sentB))
+	require.Equal(t, "2", sentB[0].Title)
+	require.Equal(t, []int64{5}, sentB[0].chatIds)
+	require.Equal(t, ParseModeHTML, sentB[0].parseMode)
+	require.Equal(t, []zerolog.Level{zerolog.ErrorLevel}, tn.cfg().LogLevels)
+
+	// Reconfiguring a stopped unit takes effect on start
+	c = newTestConfig()
+	require.Equal(t, nil, tn.Reconfigure(c))
+	tn.UnitStart()
+	require.Equal(t, nil, tn.Send(context.Background(), "3", "text"))
+	tn.UnitQuit()
+	require.Equal(t, 2, len(notifiers[newTestConfig().BotToken].Sent()))
+}
+
+// titleTextSender implements only MessageSender.
+type titleTextSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *titleTextSender) Send(ctx context.Context, subject, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, subject+"|"+message)
+	return nil
+}
+
+func TestSetSender(t *testing.T) {
+	s := &titleTextSender{}
+	tn, err := New(t.Name(), newTestConfig())
+	require.Equal(t, nil, err)
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		return nil, errors.New("Unauthorized: default sender must not be used")
+	}
+	tn.SetSender(s)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, nil, tn.SendSilent("title", "text"))
+	require.Equal(t, nil, tn.Send(context.Background(), "sync", "text"))
+	tn.UnitQuit()
+	require.ElementsMatch(t, []string{"title|text", "sync|text"}, s.sent)
+
+	// Nil restores the default sender on restart
+	tn.SetSender(nil)
+	tn.UnitStart()
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UNotAvailable
+	}, time.Second, time.Millisecond)
+	tn.UnitQuit()
+}
+
+func TestInitRetries(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.InitMaxRetries = 3
+	c.RetryBaseDelayMs = 50
+	tn := newTestNotifier(t, c, nil)
+	tn.SetInternalLogger(zerolog.Nop())
+
+	var attempts atomic.Int32
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		if attempts.Add(1) <= 2 {
+			return nil, errors.New("dial tcp: lookup api.telegram.org: temporary failure")
+		}
+		return n, nil
+	}
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UTemporarilyUnavailable
+	}, time.Second, time.Millisecond, "the unit must be temporarily unavailable while retrying")
+	require.ErrorIs(t, tn.SendAsync("rejected", "text"), ErrUnitNotAvailable)
+
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UAvailable
+	}, 5*time.Second, time.Millisecond, "the unit must become available after successful retry")
+	require.Equal(t, int32(3), attempts.Load())
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	tn.UnitQuit()
+	require.Equal(t, 1, len(n.Sent()))
+
+	// Exhausted retries
+	c.InitMaxRetries = 1
+	tn = newTestNotifier(t, c, nil)
+	tn.SetInternalLogger(zerolog.Nop())
+	attempts.Store(0)
+	release := make(chan struct{})
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		<-release
+		attempts.Add(1)
+		return nil, errors.New("Internal Server Error")
+	}
+	tn.UnitStart()
+	// Enqueued before initialization fails
+	require.Equal(t, nil, tn.SendAsync("title", "text"))
+	close(release)
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UNotAvailable
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(t, int32(2), attempts.Load())
+
+	r = tn.UnitQuit()
+	require.Equal(t, nil, r.CollateralError, "enqueued messages must be discarded, not block quit")
+	require.Equal(t, uint64(1), tn.Stats().Failed)
+}
+
+func TestInitRetriesKeepPause(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.InitMaxRetries = 3
+	c.RetryBaseDelayMs = 50
+	tn := newTestNotifier(t, c, nil)
+	tn.SetInternalLogger(zerolog.Nop())
+
+	var attempts atomic.Int32
+	succeed := make(chan struct{})
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		if attempts.Add(1) == 1 {
+			return nil, errors.New("dial tcp: lookup api.telegram.org: temporary failure")
+		}
+		<-succeed
+		return n, nil
+	}
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Eventually(t, func() bool {
+		return strings.HasPrefix(tn.AvailabilityReason(), AvailabilityReasonInitRetryingPrefix)
+	}, time.Second, time.Millisecond)
+
+	// Paused while retrying
+	r = tn.UnitPause()
+	require.Equal(t, true, r.OK)
+	require.Eventually(t, func() bool {
+		return attempts.Load() == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, AvailabilityReasonPaused, tn.AvailabilityReason(), "the retry must not override the pause")
+
+	close(succeed)
+	<-tn.tgServiceReady
+	require.Equal(t, app.UTemporarilyUnavailable, tn.UnitAvailability(), "the unit must remain paused")
+	require.Equal(t, AvailabilityReasonPaused, tn.AvailabilityReason())
+
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, app.UAvailable, tn.UnitAvailability(), "the paused unit must be resumed")
+	tn.UnitQuit()
+}
+
+func TestAvailabilityReason(t *testing.T) {
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, newTestConfig(), n)
+	require.Equal(t, AvailabilityReasonNotStarted, tn.AvailabilityReason())
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, AvailabilityReasonAvailable, tn.AvailabilityReason())
+
+	r = tn.UnitPause()
+	require.Equal(t, true, r.OK)
+	require.Equal(t, app.UTemporarilyUnavailable, tn.UnitAvailability())
+	require.Equal(t, AvailabilityReasonPaused, tn.AvailabilityReason())
+
+	tn.UnitQuit()
+	require.Equal(t, AvailabilityReasonStopped, tn.AvailabilityReason())
+
+	// Failed initialization
+	tn.SetSender(nil)
+	tn.newNotifier = func(ctx context.Context, c *validatedConfig) (notify.Notifier, error) {
+		return nil, &apiError{Code: 401, Description: "Unauthorized"}
+	}
+	r = tn.UnitStart()
+	require.Equal(t, true, r.OK)
+	require.Eventually(t, func() bool {
+		return tn.UnitAvailability() == app.UNotAvailable
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, AvailabilityReasonInitFailedPrefix+"Unauthorized", tn.AvailabilityReason())
+	tn.UnitQuit()
+}
+
+func TestSendf(t *testing.T) {
+	n := &fakeNotifier{delay: 200 * time.Millisecond}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	c.MsgBufSize = 1
+	c.OverflowPolicy = OverflowPolicyDropNewest
+	tn := newTestNotifier(t, c, n)
+
+	require.ErrorIs(t, tn.SendAsyncf("title", "%d", 1), ErrUnitNotAvailable)
+	require.ErrorIs(t, tn.Sendf(context.Background(), "title", "%d", 1), ErrUnitNotAvailable)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	require.Equal(t, nil, tn.Sendf(context.Background(), "sync", "disk %s is %d%% full", "/dev/sda1", 95))
+	require.Equal(t, nil, tn.SendAsyncf("async", "%d errors in %v", 3, time.Minute))
+
+	// The overflow policy applies
+	require.Eventually(t, func() bool { return len(tn.tgMsgChan) == 0 }, time.Second, time.Millisecond)
+	require.Equal(t, nil, tn.SendAsyncf("buffered", "%d", 1))
+	require.ErrorIs(t, tn.SendAsyncf("dropped", "%d", 2), ErrMsgBufferFull)
+	require.Equal(t, nil, tn.Flush(context.Background()))
+	tn.UnitQuit()
+
+	sent := n.Sent()
+	require.Equal(t, 3, len(sent))
+	require.Equal(t, "disk /dev/sda1 is 95% full", sent[0].Text)
+	require.Equal(t, "3 errors in 1m0s", sent[1].Text)
+	require.Equal(t, "1", sent[2].Text)
+}
+
+func TestSendFields(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	fields := map[string]any{
+		"user_id":    42,
+		"request_id": "abc-123",
+		"error":      errors.New("timeout"),
+		"elapsed":    1500 * time.Millisecond,
+	}
+	// The keys are sorted regardless of the map iteration order
+	for i := 0; i < 3; i++ {
+		require.Equal(t, nil, tn.SendFields("request failed", fields))
+	}
+	require.Equal(t, nil, tn.SendFields("empty", nil))
+	tn.UnitQuit()
+
+	const expected = "elapsed: 1.5s\nerror: timeout\nrequest_id: abc-123\nuser_id: 42"
+	require.Equal(t, []string{expected, expected, expected, ""}, sentTexts(n))
+}
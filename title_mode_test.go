@@ -0,0 +1,53 @@
+package telegram_notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTitleMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode    string
+		sent    string
+		logSent string
+	}{
+		{mode: "", sent: "deploy\nversion 1.2 is live", logSent: "ERROR\ndisk full"},
+		{mode: TitleModeSeparate, sent: "deploy\nversion 1.2 is live", logSent: "ERROR\ndisk full"},
+		{mode: TitleModeInline, sent: "deploy: version 1.2 is live", logSent: "ERROR: disk full"},
+		{mode: TitleModeNone, sent: "version 1.2 is live", logSent: "disk full"},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			s := newFakeBotAPIServer(t, nil)
+			c := newTestConfig()
+			c.LogLevels = []string{"error"}
+			c.SendConcurrency = 1
+			c.TitleMode = tc.mode
+			tn := newBotAPITestNotifier(t, s, c)
+
+			r := tn.UnitStart()
+			require.Equal(t, true, r.OK)
+			require.Equal(t, nil, tn.Send(context.Background(), "deploy", "version 1.2 is live"))
+			tn.ForwardLog(zerolog.ErrorLevel, "disk full")
+			tn.UnitQuit()
+
+			requests := s.Requests()
+			require.Equal(t, 3, len(requests))
+			require.Equal(t, tc.sent, requests[1].Params["text"])
+			require.Equal(t, tc.logSent, requests[2].Params["text"])
+		})
+	}
+
+	c := newTestConfig()
+	c.TitleMode = "above"
+	require.ErrorIs(t, c.Validate(), ErrBadTitleMode)
+}
+
+func TestComposeText(t *testing.T) {
+	require.Equal(t, "title\ntext", composeText("title", "text", TitleModeSeparate))
+	require.Equal(t, "title: text", composeText("title", "text", TitleModeInline))
+	require.Equal(t, "text", composeText("", "text", TitleModeInline))
+	require.Equal(t, "text", composeText("title", "text", TitleModeNone))
+}
@@ -0,0 +1,75 @@
+package telegram_notifier
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// SendSpanInfo describes the message a send span is started for.
+type SendSpanInfo struct {
+	// ChatCount is the number of chats the message is sent to.
+	ChatCount int
+
+	// MessageLength is the length of the message (title and text)
+	// in characters.
+	MessageLength int
+
+	// Async is true for the messages sent asynchronously.
+	Async bool
+}
+
+// Tracer creates a span for every sent message, see SetTracer.
+// The otel_tracing package implements it using OpenTelemetry,
+// so that this package doesn't depend on OpenTelemetry.
+type Tracer interface {
+	// StartSendSpan starts the span as a child of the span in ctx, if any,
+	// and returns the context containing the started span.
+	StartSendSpan(ctx context.Context, info SendSpanInfo) (context.Context, SendSpan)
+}
+
+// SendSpan is a span started by Tracer.
+type SendSpan interface {
+	// End ends the span with the result of the send,
+	// err is nil if the message was sent successfully.
+	End(err error)
+}
+
+// SetTracer sets the tracer used to create a span for every message sent
+// by the unit, it is thread-safe. Asynchronous message spans are children
+// of the span in the context passed to SendAsyncCtx. Nil disables tracing.
+func (u *TelegramNotifier) SetTracer(t Tracer) {
+	u.tracerLock.Lock()
+	defer u.tracerLock.Unlock()
+	u.tracer = t
+}
+
+func (u *TelegramNotifier) loadTracer() Tracer {
+	u.tracerLock.Lock()
+	defer u.tracerLock.Unlock()
+	return u.tracer
+}
+
+// noopSendSpan is used when tracing is disabled.
+type noopSendSpan struct{}
+
+func (noopSendSpan) End(err error) {}
+
+// startSendSpan starts the span for the message if the tracer is set
+// and returns the message with the context containing the span.
+func (u *TelegramNotifier) startSendSpan(msg TelegramMessage, async bool) (TelegramMessage, SendSpan) {
+	t := u.loadTracer()
+	if t == nil {
+		return msg, noopSendSpan{}
+	}
+	chatCount := len(msg.chatIds)
+	if msg.chatIds == nil {
+		chatCount = len(u.cfg().ChatIds)
+	}
+	ctx, span := t.StartSendSpan(msg.ctx, SendSpanInfo{
+		ChatCount:     chatCount,
+		MessageLength: utf8.RuneCountInString(msg.Title) + 1 + utf8.RuneCountInString(msg.Text),
+		Async:         async,
+	})
+	msg.ctx = ctx
+	return msg, span
+}
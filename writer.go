@@ -0,0 +1,52 @@
+package telegram_notifier
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Writer returns an io.Writer that asynchronously sends each line
+// written to it as a separate message with the specified title.
+// Partial lines are buffered until a newline is written,
+// empty lines are skipped. The writer is thread-safe,
+// so it can be used e.g. with log.SetOutput.
+func (u *TelegramNotifier) Writer(title string) io.Writer {
+	return &lineWriter{u: u, title: title}
+}
+
+type lineWriter struct {
+	mu    sync.Mutex
+	u     *TelegramNotifier
+	title string
+	buf   []byte
+}
+
+// Write implements io.Writer. All bytes of p are always consumed,
+// the returned error combines the errors of sending the complete lines.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimSuffix(w.buf[:i], []byte{'\r'})
+		if len(line) > 0 {
+			if err := w.u.SendAsync(w.title, string(line)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		w.buf = w.buf[i+1:]
+	}
+	// Release the memory of the sent lines
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package telegram_notifier
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	n := &fakeNotifier{}
+	c := newTestConfig()
+	c.SendConcurrency = 1
+	tn := newTestNotifier(t, c, n)
+
+	w := tn.Writer("LOG")
+
+	// Not available before start
+	_, err := w.Write([]byte("line 0\n"))
+	require.ErrorIs(t, err, ErrUnitNotAvailable)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	for _, s := range []string{"line 1\nline", " 2\r\n", "\n", "line 3\nline 4\n", "partial"} {
+		written, err := w.Write([]byte(s))
+		require.Equal(t, nil, err)
+		require.Equal(t, len(s), written)
+	}
+
+	logger := log.New(tn.Writer("STD LOG"), "", 0)
+	logger.Println("line 5")
+
+	tn.UnitQuit()
+
+	var texts []string
+	for _, m := range n.Sent() {
+		texts = append(texts, m.Title+": "+m.Text)
+	}
+	require.Equal(t, []string{
+		"LOG: line 1",
+		"LOG: line 2",
+		"LOG: line 3",
+		"LOG: line 4",
+		"STD LOG: line 5",
+	}, texts)
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	n := &fakeNotifier{}
+	tn := newTestNotifier(t, newTestConfig(), n)
+
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	w := tn.Writer("LOG")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := fmt.Fprintf(w, "line %d\n", i)
+			require.Equal(t, nil, err)
+		}(i)
+	}
+	wg.Wait()
+
+	tn.UnitQuit()
+
+	var texts, expected []string
+	for i, m := range n.Sent() {
+		texts = append(texts, m.Text)
+		expected = append(expected, fmt.Sprintf("line %d", i))
+	}
+	sort.Strings(texts)
+	require.Equal(t, expected, texts)
+	require.Equal(t, 10, len(texts))
+}
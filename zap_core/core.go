@@ -0,0 +1,112 @@
+// Package zap_core forwards zap log entries to Telegram
+// via TelegramNotifier. It is a separate package so that
+// the telegram_notifier package doesn't depend on zap.
+package zap_core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/zap/zapcore"
+)
+
+// Target is implemented by *telegram_notifier.TelegramNotifier.
+type Target interface {
+	ForwardLog(level zerolog.Level, message string)
+	AcceptsLogLevel(level zerolog.Level) bool
+}
+
+// Core implements zapcore.Core. The entries having the log levels
+// and prefixes configured for the TelegramNotifier are sent
+// asynchronously, so logging is not blocked. Use zapcore.NewTee
+// to combine it with the existing core.
+type Core struct {
+	target Target
+	fields []zapcore.Field
+}
+
+// New creates a Core that forwards the log entries to the target.
+func New(target Target) *Core {
+	return &Core{target: target}
+}
+
+// levelToZerolog maps zap levels to zerolog levels.
+func levelToZerolog(level zapcore.Level) zerolog.Level {
+	switch level {
+	case zapcore.DebugLevel:
+		return zerolog.DebugLevel
+	case zapcore.InfoLevel:
+		return zerolog.InfoLevel
+	case zapcore.WarnLevel:
+		return zerolog.WarnLevel
+	case zapcore.ErrorLevel:
+		return zerolog.ErrorLevel
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		return zerolog.PanicLevel
+	case zapcore.FatalLevel:
+		return zerolog.FatalLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
+
+// Enabled implements zapcore.LevelEnabler.
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return c.target.AcceptsLogLevel(levelToZerolog(level))
+}
+
+// With implements zapcore.Core.
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	return &Core{
+		target: c.target,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+// Check implements zapcore.Core.
+func (c *Core) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core. The fields are appended
+// to the message as key=value pairs sorted by key.
+func (c *Core) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	c.target.ForwardLog(levelToZerolog(e.Level), c.format(e, fields))
+	return nil
+}
+
+// Sync implements zapcore.Core. The messages are sent asynchronously,
+// use TelegramNotifier.Flush to wait for them.
+func (c *Core) Sync() error {
+	return nil
+}
+
+func (c *Core) format(e zapcore.Entry, fields []zapcore.Field) string {
+	if len(c.fields) == 0 && len(fields) == 0 {
+		return e.Message
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, enc.Fields[k])
+	}
+	return b.String()
+}
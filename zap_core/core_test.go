@@ -0,0 +1,60 @@
+package zap_core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/igulib/telegram_notifier"
+)
+
+// fakeSender records the sent messages.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *fakeSender) Send(ctx context.Context, subject, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, subject+": "+message)
+	return nil
+}
+
+func TestCore(t *testing.T) {
+	tn, err := telegram_notifier.New(t.Name(), &telegram_notifier.Config{
+		BotToken:          "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789",
+		ChatIds:           []int64{1},
+		LogLevels:         []string{">=warning"},
+		MaxMessagesPerSec: -1,
+		SendConcurrency:   1,
+	})
+	require.Equal(t, nil, err)
+	s := &fakeSender{}
+	tn.SetSender(s)
+	r := tn.UnitStart()
+	require.Equal(t, true, r.OK)
+
+	// The existing core receives all entries
+	observed, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapcore.NewTee(observed, New(tn))).With(zap.String("service", "api"))
+
+	logger.Info("info is not forwarded")
+	logger.Warn("disk full", zap.String("disk", "/dev/sda"), zap.Int("free", 0))
+	logger.Error("request failed", zap.Error(errors.New("timeout")), zap.Duration("after", time.Second))
+	require.Equal(t, 3, logs.Len())
+
+	tn.UnitQuit()
+
+	require.Equal(t, []string{
+		"WARNING: disk full disk=/dev/sda free=0 service=api",
+		"ERROR: request failed after=1s error=timeout service=api",
+	}, s.sent)
+}